@@ -0,0 +1,839 @@
+// Package atomtest provides an in-process fake of the juweitong endpoints used
+// by atom.Client, so that the login and liking flows can be exercised offline.
+package atomtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/samber/lo"
+)
+
+const (
+	kRootPath       = "/neighbour"
+	kAuthCookie     = "atomtest_auth"
+	kApprovedStatus = "已通过"
+	kTimeLayout     = "2006-01-02 15:04"
+)
+
+// time zone of the timestamps shown on juweitong
+var kTimeZone = time.FixedZone("CST", 8*60*60)
+
+var templateFuncs = template.FuncMap{
+	"formatTime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(kTimeZone).Format(kTimeLayout)
+	},
+}
+
+// Names of the builtin post kinds
+var (
+	KindNotices   = atom.KindNotices.Name
+	KindMoments   = atom.KindMoments.Name
+	KindCCPPosts  = atom.KindCCPPosts.Name
+	KindProposals = atom.KindProposals.Name
+)
+
+type kindConfig struct {
+	listPath  string
+	viewPath  string
+	viewParam string
+	likeText  string
+	likedText string
+}
+
+func newKindConfig(k atom.PostKind) kindConfig {
+	viewPath, viewParam, _ := strings.Cut(k.ViewApiPath, "?")
+	return kindConfig{
+		listPath:  k.ListApiPath,
+		viewPath:  viewPath,
+		viewParam: strings.TrimSuffix(viewParam, "="),
+		likeText:  k.LikeText,
+		likedText: "已" + k.LikeText,
+	}
+}
+
+// Community is a community the fake user is bound to
+type Community struct {
+	Name     string
+	MemberId string
+	Pending  bool // pending communities are not approved yet
+}
+
+// Attachment is a file attached to a post
+type Attachment struct {
+	Name string
+	URL  string
+}
+
+// Post is a post published in a community
+type Post struct {
+	ViewId      string // the id for reading
+	LikeId      string // the id for liking
+	Title       string
+	Author      string
+	Time        time.Time // not shown if zero
+	Content     string    // html body of the post
+	Attachments []Attachment
+	Proposal    *Proposal // set if the post is a proposal
+	Thread      []Comment // comments shown on the post, the earliest first
+	Likes       int
+	Comments    int
+	Liked       bool // whether the fake user has liked the post
+}
+
+func (p *Post) clone() *Post {
+	c := *p
+	c.Proposal = p.Proposal.clone()
+	c.Thread = append([]Comment(nil), p.Thread...)
+	return &c
+}
+
+// Comment is a comment on a post
+type Comment struct {
+	Id      string
+	Author  string
+	Text    string
+	Time    time.Time
+	ReplyTo string // id of the replied comment
+}
+
+// VoteChoice is a choice which can be voted for a proposal
+type VoteChoice struct {
+	Id    string
+	Text  string
+	Votes int
+}
+
+// Proposal is the voting information of a proposal
+type Proposal struct {
+	Status   string // open, closed or passed
+	Deadline time.Time
+	Choices  []VoteChoice
+	Voted    string // id of the choice voted by the fake user
+}
+
+func (p *Proposal) clone() *Proposal {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	c.Choices = append([]VoteChoice(nil), p.Choices...)
+	return &c
+}
+
+type login struct {
+	mtx      sync.Mutex
+	conn     *websocket.Conn
+	scanned  bool
+	rejected bool
+}
+
+// Server is a fake juweitong server. The zero value is not usable, create one
+// with NewServer.
+type Server struct {
+	srv   *httptest.Server
+	kinds map[string]kindConfig // post kind name -> config
+
+	mtx          sync.Mutex
+	userId       string
+	communities  []Community
+	posts        map[string]map[string][]*Post // member id -> kind -> posts
+	tokens       map[string]string             // connection token -> connection id
+	logins       map[string]*login             // connection id -> login
+	sessions     map[string]*session           // auth cookie value -> session
+	failures     map[string][]int              // path -> status codes of the next responses
+	loginInPlace bool                          // serve the login page in place of redirecting to it
+}
+
+// NewServer starts a fake server serving the post kinds registered in atom at
+// the time of the call. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		kinds:    make(map[string]kindConfig),
+		userId:   "atomtest-user",
+		posts:    make(map[string]map[string][]*Post),
+		tokens:   make(map[string]string),
+		logins:   make(map[string]*login),
+		sessions: make(map[string]*session),
+		failures: make(map[string][]int),
+	}
+	for _, k := range atom.PostKinds() {
+		s.kinds[k.Name] = newKindConfig(k)
+	}
+	s.srv = httptest.NewServer(s.router())
+	return s
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// URL returns the base url to pass to atom.WithBaseURL
+func (s *Server) URL() string {
+	return s.srv.URL + kRootPath
+}
+
+// SetUserId sets the id reported for the logged in user
+func (s *Server) SetUserId(id string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.userId = id
+}
+
+// AddCommunity binds the fake user to a community
+func (s *Server) AddCommunity(c Community) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.communities = append(s.communities, c)
+}
+
+// AddPost publishes a post of the given kind in the community of memberId
+func (s *Server) AddPost(memberId string, kind string, p Post) {
+	if _, ok := s.kinds[kind]; !ok {
+		panic("atomtest: unknown post kind " + kind)
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	byKind, ok := s.posts[memberId]
+	if !ok {
+		byKind = make(map[string][]*Post)
+		s.posts[memberId] = byKind
+	}
+	byKind[kind] = append(byKind[kind], p.clone())
+	// keep the latest post first like the real list
+	sort.SliceStable(byKind[kind], func(i, j int) bool {
+		return byKind[kind][i].Time.After(byKind[kind][j].Time)
+	})
+}
+
+// Post returns a copy of the post with the like id
+func (s *Server) Post(memberId string, kind string, likeId string) (Post, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	p := s.findPostNoLock(memberId, kind, func(p *Post) bool { return p.LikeId == likeId })
+	if p == nil {
+		return Post{}, false
+	}
+	return *p.clone(), true
+}
+
+// IsLiked returns true if the post with the like id has been liked
+func (s *Server) IsLiked(memberId string, kind string, likeId string) bool {
+	p, _ := s.Post(memberId, kind, likeId)
+	return p.Liked
+}
+
+// ScanQRCode simulates scanning the qr code at qrUrl with WeChat, which
+// completes the pending login.
+func (s *Server) ScanQRCode(qrUrl string) error {
+	return s.scanQRCode(qrUrl, false)
+}
+
+// RejectQRCode simulates scanning the qr code at qrUrl, but the login is
+// rejected when the client confirms it.
+func (s *Server) RejectQRCode(qrUrl string) error {
+	return s.scanQRCode(qrUrl, true)
+}
+
+// ExpireQRCode expires the qr code at qrUrl by closing the login connection
+func (s *Server) ExpireQRCode(qrUrl string) error {
+	l, err := s.pendingLogin(qrUrl)
+	if err != nil {
+		return err
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.conn.Close()
+}
+
+// ExpireSessions invalidates the sessions of all logged in clients, their
+// requests are redirected to the login page afterwards
+func (s *Server) ExpireSessions() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.sessions = make(map[string]*session)
+}
+
+// ServeLoginPage makes requests without a valid session get the login page
+// with a 200 at the requested url instead of a redirect to it, which the
+// upstream may do for some pages
+func (s *Server) ServeLoginPage(inPlace bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.loginInPlace = inPlace
+}
+
+func (s *Server) pendingLogin(qrUrl string) (*login, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	l, ok := s.logins[path.Base(qrUrl)]
+	if !ok {
+		return nil, fmt.Errorf("atomtest: no pending login for %s", qrUrl)
+	}
+	return l, nil
+}
+
+func (s *Server) scanQRCode(qrUrl string, reject bool) error {
+	l, err := s.pendingLogin(qrUrl)
+	if err != nil {
+		return err
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.scanned = true
+	l.rejected = reject
+	return l.conn.WriteJSON(signalRResponse{[]signalRMessage{{BindUser: true}}})
+}
+
+// FailNext makes the next n requests to path, e.g. /community/title_like,
+// fail with the status code
+func (s *Server) FailNext(path string, n int, code int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures[kRootPath+path] = append(s.failures[kRootPath+path], code)
+	}
+}
+
+// injectFailures fails the requests scheduled by FailNext
+func (s *Server) injectFailures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mtx.Lock()
+		var code int
+		if codes := s.failures[r.URL.Path]; len(codes) > 0 {
+			code = codes[0]
+			s.failures[r.URL.Path] = codes[1:]
+		}
+		s.mtx.Unlock()
+		if code != 0 {
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) findPostNoLock(memberId string, kind string, pred func(p *Post) bool) *Post {
+	for _, p := range s.posts[memberId][kind] {
+		if pred(p) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Server) router() http.Handler {
+	r := mux.NewRouter().PathPrefix(kRootPath).Subrouter()
+	r.Use(s.injectFailures)
+	r.HandleFunc("/authorize/negotiate", s.negotiate)
+	r.HandleFunc("/authorize/connect", s.connect)
+	r.HandleFunc("/authorize/start", s.start)
+	r.HandleFunc("/home/qr_login_more_v1", s.qrLoginMore)
+	r.HandleFunc("/home/qr_login_do", s.qrLoginDo)
+	r.HandleFunc("/home/login", s.loginPage)
+	r.HandleFunc("/qrcode/{id}", s.qrcode)
+	r.HandleFunc("/api/register/member/bind", s.requireLogin(s.memberBind))
+	r.HandleFunc("/api/member/switch/{id}", s.requireLogin(s.memberSwitch))
+	r.HandleFunc("/home/home", s.requireLogin(s.home))
+	r.HandleFunc("/community/title_like", s.requireLogin(s.like))
+	r.HandleFunc("/community/title_unlike", s.requireLogin(s.unlike))
+	r.HandleFunc("/community/proposal_vote", s.requireLogin(s.vote))
+	r.HandleFunc("/community/comment_add", s.requireLogin(s.addComment))
+	for kind, config := range s.kinds {
+		r.HandleFunc(config.listPath, s.requireLogin(s.listPosts(kind)))
+		r.HandleFunc(config.viewPath, s.requireLogin(s.viewPost(kind)))
+	}
+	return r
+}
+
+// requireLogin redirects requests without a valid session to the login page,
+// or serves it in place if told by ServeLoginPage
+func (s *Server) requireLogin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(kAuthCookie)
+		s.mtx.Lock()
+		ok := err == nil && s.sessions[c.Value] != nil
+		inPlace := s.loginInPlace
+		s.mtx.Unlock()
+		if !ok {
+			if inPlace {
+				s.loginPage(w, r)
+				return
+			}
+			http.Redirect(w, r, kRootPath+"/home/login", http.StatusFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// session is the state kept by the server for a logged in client, shared by
+// all the requests carrying the same auth cookie as on juweitong
+type session struct {
+	member string // member id of the current community, empty if not switched
+}
+
+// sessionNoLock returns the session of the request, nil if not logged in
+func (s *Server) sessionNoLock(r *http.Request) *session {
+	c, err := r.Cookie(kAuthCookie)
+	if err != nil {
+		return nil
+	}
+	return s.sessions[c.Value]
+}
+
+// currentMemberNoLock returns the member id of the current community of the request
+func (s *Server) currentMemberNoLock(r *http.Request) string {
+	if ss := s.sessionNoLock(r); ss != nil && ss.member != "" {
+		return ss.member
+	}
+	for _, c := range s.communities {
+		if !c.Pending {
+			return c.MemberId
+		}
+	}
+	return ""
+}
+
+type signalRMessage struct {
+	Init     bool `json:"init,omitempty"`
+	BindUser bool `json:"bindUser,omitempty"`
+}
+
+type signalRResponse struct {
+	M []signalRMessage
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeApiResult(w http.ResponseWriter, err error) {
+	res := struct {
+		Error   bool   `json:"error"`
+		Message string `json:"message,omitempty"`
+	}{}
+	if err != nil {
+		res.Error = true
+		res.Message = err.Error()
+	}
+	writeJSON(w, res)
+}
+
+func writeHTML(w http.ResponseWriter, t *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) negotiate(w http.ResponseWriter, r *http.Request) {
+	id, token := uuid.NewString(), uuid.NewString()
+	s.mtx.Lock()
+	s.tokens[token] = id
+	s.mtx.Unlock()
+	writeJSON(w, map[string]string{
+		"ConnectionToken": token,
+		"ConnectionId":    id,
+	})
+}
+
+var upgrader = websocket.Upgrader{}
+
+func (s *Server) connect(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	id, ok := s.tokens[r.URL.Query().Get("connectionToken")]
+	s.mtx.Unlock()
+	if !ok {
+		http.Error(w, "invalid connection token", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// the client asks for the qr code first
+	if _, _, err := conn.ReadMessage(); err != nil {
+		return
+	}
+	l := &login{conn: conn}
+	s.mtx.Lock()
+	s.logins[id] = l
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		delete(s.logins, id)
+		s.mtx.Unlock()
+	}()
+
+	l.mtx.Lock()
+	err = conn.WriteJSON(signalRResponse{[]signalRMessage{{Init: true}}})
+	l.mtx.Unlock()
+	if err != nil {
+		return
+	}
+	// wait for the client to hang up
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"Response": "started"})
+}
+
+func (s *Server) qrLoginMore(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.URL()+"/qrcode/"+r.URL.Query().Get("id"))
+}
+
+// a 1x1 transparent gif
+var qrcodeImage = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+func (s *Server) qrcode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/gif")
+	w.Write(qrcodeImage)
+}
+
+func (s *Server) qrLoginDo(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	l, ok := s.logins[r.URL.Query().Get("id")]
+	s.mtx.Unlock()
+	if !ok {
+		http.Error(w, "invalid login", http.StatusBadRequest)
+		return
+	}
+	l.mtx.Lock()
+	scanned, rejected := l.scanned, l.rejected
+	l.mtx.Unlock()
+	if !scanned {
+		http.Error(w, "qr code not scanned", http.StatusBadRequest)
+		return
+	}
+	if rejected {
+		http.Error(w, "login rejected", http.StatusForbidden)
+		return
+	}
+
+	token := uuid.NewString()
+	s.mtx.Lock()
+	s.sessions[token] = &session{}
+	s.mtx.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: kAuthCookie, Value: token, Path: kRootPath})
+	writeApiResult(w, nil)
+}
+
+var loginPageTemplate = template.Must(template.New("login").Parse(`<html>
+<head><title>登录</title></head>
+<body><div id="qrLogin">请使用微信扫码登录</div></body>
+</html>`))
+
+func (s *Server) loginPage(w http.ResponseWriter, r *http.Request) {
+	writeHTML(w, loginPageTemplate, nil)
+}
+
+func (s *Server) memberBind(w http.ResponseWriter, r *http.Request) {
+	type binding struct {
+		CommunityName string `json:"community_name"`
+		Status        string `json:"status"`
+		Member        string `json:"member"`
+	}
+	var res struct {
+		Id    string    `json:"wx"`
+		Binds []binding `json:"binds"`
+	}
+
+	s.mtx.Lock()
+	res.Id = s.userId
+	for _, c := range s.communities {
+		status := kApprovedStatus
+		if c.Pending {
+			status = "待审核"
+		}
+		res.Binds = append(res.Binds, binding{c.Name, status, c.MemberId})
+	}
+	s.mtx.Unlock()
+	writeJSON(w, res)
+}
+
+func (s *Server) memberSwitch(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	s.mtx.Lock()
+	var found bool
+	for _, c := range s.communities {
+		if c.MemberId == id && !c.Pending {
+			found = true
+			break
+		}
+	}
+	ss := s.sessionNoLock(r)
+	if found && ss != nil {
+		ss.member = id
+	}
+	s.mtx.Unlock()
+	if !found || ss == nil {
+		writeApiResult(w, fmt.Errorf("invalid member: %s", id))
+		return
+	}
+	writeApiResult(w, nil)
+}
+
+var homeTemplate = template.Must(template.New("home").Parse(`<html>
+<head><title>社区通</title></head>
+<body>
+<div id="changeMember"><span>{{.}}</span></div>
+</body>
+</html>`))
+
+func (s *Server) home(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	member := s.currentMemberNoLock(r)
+	var name string
+	for _, c := range s.communities {
+		if c.MemberId == member {
+			name = c.Name
+		}
+	}
+	s.mtx.Unlock()
+	writeHTML(w, homeTemplate, name)
+}
+
+var listTemplate = template.Must(template.New("list").Funcs(templateFuncs).Parse(`<html><body>
+{{- range .Posts}}
+<div id="p_{{.LikeId}}" class="list-item">
+<a {{call $.Href .ViewId}}><h4 class="title">{{.Title}}</h4></a>
+<span class="author">{{.Author}}</span>
+<span class="time">{{formatTime .Time}}</span>
+<span class="like-count{{if .Liked}} liked{{end}}">{{.Likes}}</span>
+<span class="comment-count">{{.Comments}}</span>
+</div>
+{{- end}}
+</body></html>`))
+
+func (s *Server) listPosts(kind string) http.HandlerFunc {
+	config := s.kinds[kind]
+	return func(w http.ResponseWriter, r *http.Request) {
+		begin, _ := strconv.Atoi(r.URL.Query().Get("begin"))
+		count, err := strconv.Atoi(r.URL.Query().Get("count"))
+		if err != nil {
+			count = 10
+		}
+
+		s.mtx.Lock()
+		posts := s.posts[s.currentMemberNoLock(r)][kind]
+		var page []Post
+		for i := begin; i >= 0 && i < len(posts) && i < begin+count; i++ {
+			page = append(page, *posts[i])
+		}
+		s.mtx.Unlock()
+
+		writeHTML(w, listTemplate, struct {
+			Href  func(viewId string) template.HTMLAttr
+			Posts []Post
+		}{
+			func(viewId string) template.HTMLAttr {
+				return template.HTMLAttr(`href="` + html.EscapeString("javascript:openView('"+
+					kRootPath+config.viewPath+"?"+config.viewParam+"="+viewId+"')") + `"`)
+			},
+			page,
+		})
+	}
+}
+
+var viewTemplate = template.Must(template.New("view").Funcs(templateFuncs).Parse(`<html>
+<head><title>{{.Post.Title}}</title></head>
+<body>
+<h3 class="title">{{.Post.Title}}</h3>
+<div class="info"><span class="publisher">{{.Post.Author}}</span><span class="time">{{formatTime .Post.Time}}</span></div>
+<div class="content">{{.Content}}</div>
+{{- if .Post.Attachments}}
+<div class="attachments">
+{{- range .Post.Attachments}}
+<a class="attachment" href="{{.URL}}">{{.Name}}</a>
+{{- end}}
+</div>
+{{- end}}
+{{- with .Post.Proposal}}
+<div class="proposal" data-case="{{$.Post.LikeId}}" data-status="{{.Status}}">
+<span class="deadline">{{formatTime .Deadline}}</span>
+<ul class="choices">
+{{- range .Choices}}
+<li class="choice{{if eq .Id $.Post.Proposal.Voted}} voted{{end}}" data-choice="{{.Id}}"><span class="choice-text">{{.Text}}</span><span class="vote-count">{{.Votes}}</span></li>
+{{- end}}
+</ul>
+</div>
+{{- end}}
+<div class="actions"><span id="cmdLike">{{.LikeText}}</span></div>
+<ul class="comments">
+{{- range .Post.Thread}}
+<li class="comment" data-id="{{.Id}}"{{if .ReplyTo}} data-reply="{{.ReplyTo}}"{{end}}><span class="comment-author">{{.Author}}</span><span class="comment-time">{{formatTime .Time}}</span><p class="comment-text">{{.Text}}</p></li>
+{{- end}}
+</ul>
+</body>
+</html>`))
+
+func (s *Server) viewPost(kind string) http.HandlerFunc {
+	config := s.kinds[kind]
+	return func(w http.ResponseWriter, r *http.Request) {
+		viewId := r.URL.Query().Get(config.viewParam)
+		s.mtx.Lock()
+		p := s.findPostNoLock(s.currentMemberNoLock(r), kind, func(p *Post) bool {
+			return p.ViewId == viewId
+		})
+		var post Post
+		if p != nil {
+			post = *p.clone()
+		}
+		s.mtx.Unlock()
+		if p == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		likeText := config.likeText
+		if post.Liked {
+			likeText = config.likedText
+		}
+		writeHTML(w, viewTemplate, struct {
+			Post     Post
+			Content  template.HTML
+			LikeText string
+		}{post, template.HTML(post.Content), likeText})
+	}
+}
+
+func (s *Server) like(w http.ResponseWriter, r *http.Request) {
+	likeId := r.URL.Query().Get("title")
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	member := s.currentMemberNoLock(r)
+	for kind := range s.kinds {
+		if p := s.findPostNoLock(member, kind, func(p *Post) bool { return p.LikeId == likeId }); p != nil {
+			if p.Proposal != nil && p.Proposal.Status != "open" {
+				writeApiResult(w, errors.New("投票已结束"))
+				return
+			}
+			if !p.Liked {
+				p.Liked = true
+				p.Likes++
+			}
+			writeApiResult(w, nil)
+			return
+		}
+	}
+	writeApiResult(w, fmt.Errorf("post not found: %s", likeId))
+}
+
+func (s *Server) unlike(w http.ResponseWriter, r *http.Request) {
+	likeId := r.URL.Query().Get("title")
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	member := s.currentMemberNoLock(r)
+	for kind := range s.kinds {
+		if p := s.findPostNoLock(member, kind, func(p *Post) bool { return p.LikeId == likeId }); p != nil {
+			if p.Proposal != nil && p.Proposal.Status != "open" {
+				writeApiResult(w, errors.New("投票已结束"))
+				return
+			}
+			if !p.Liked {
+				writeApiResult(w, errors.New("未点赞"))
+				return
+			}
+			p.Liked = false
+			p.Likes--
+			writeApiResult(w, nil)
+			return
+		}
+	}
+	writeApiResult(w, fmt.Errorf("post not found: %s", likeId))
+}
+
+func (s *Server) vote(w http.ResponseWriter, r *http.Request) {
+	caseId := r.URL.Query().Get("caseId")
+	choice := r.URL.Query().Get("choice")
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	p := s.findPostNoLock(s.currentMemberNoLock(r), KindProposals, func(p *Post) bool {
+		return p.LikeId == caseId && p.Proposal != nil
+	})
+	if p == nil {
+		writeApiResult(w, fmt.Errorf("proposal not found: %s", caseId))
+		return
+	}
+	if p.Proposal.Status != "open" {
+		writeApiResult(w, errors.New("投票已结束"))
+		return
+	}
+	if p.Proposal.Voted != "" {
+		writeApiResult(w, errors.New("已投票"))
+		return
+	}
+	for i := range p.Proposal.Choices {
+		if c := &p.Proposal.Choices[i]; c.Id == choice {
+			c.Votes++
+			p.Proposal.Voted = choice
+			writeApiResult(w, nil)
+			return
+		}
+	}
+	writeApiResult(w, fmt.Errorf("invalid choice: %s", choice))
+}
+
+func (s *Server) addComment(w http.ResponseWriter, r *http.Request) {
+	likeId := r.URL.Query().Get("title")
+	content := r.URL.Query().Get("content")
+	replyTo := r.URL.Query().Get("replyTo")
+	if content == "" {
+		writeApiResult(w, errors.New("评论内容不能为空"))
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	member := s.currentMemberNoLock(r)
+	for kind := range s.kinds {
+		p := s.findPostNoLock(member, kind, func(p *Post) bool { return p.LikeId == likeId })
+		if p == nil {
+			continue
+		}
+		if replyTo != "" && !lo.ContainsBy(p.Thread, func(c Comment) bool { return c.Id == replyTo }) {
+			writeApiResult(w, fmt.Errorf("comment not found: %s", replyTo))
+			return
+		}
+		p.Thread = append(p.Thread, Comment{
+			Id:      uuid.NewString(),
+			Author:  s.userId,
+			Text:    content,
+			Time:    time.Now(),
+			ReplyTo: replyTo,
+		})
+		p.Comments++
+		writeApiResult(w, nil)
+		return
+	}
+	writeApiResult(w, fmt.Errorf("post not found: %s", likeId))
+}
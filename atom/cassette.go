@@ -0,0 +1,373 @@
+package atom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const kRedacted = "redacted"
+
+// ErrNotRecorded is returned when replaying a request which is not found in
+// the cassette
+var ErrNotRecorded = errors.New("not recorded in the cassette")
+
+// Interaction is a recorded http request and its response
+type Interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Err    string      `json:"err,omitempty"` // set if no response was received
+}
+
+// Frame is a recorded websocket message
+type Frame struct {
+	Sent bool   `json:"sent,omitempty"` // whether the message is sent by the client
+	Type int    `json:"type"`
+	Data string `json:"data"`
+}
+
+// WebSocket is a recorded websocket connection
+type WebSocket struct {
+	URL    string
+	Frames []Frame
+}
+
+// Cassette is the upstream traffic of a client recorded by a Recorder
+type Cassette struct {
+	Interactions []Interaction
+	WebSockets   []WebSocket
+}
+
+// cassetteLine is a line of a cassette file, exactly one field is set
+type cassetteLine struct {
+	HTTP *Interaction `json:"http,omitempty"`
+	Dial *wsDial      `json:"dial,omitempty"`
+	WS   *wsFrame     `json:"ws,omitempty"`
+}
+
+type wsDial struct {
+	Conn int    `json:"conn"`
+	URL  string `json:"url"`
+}
+
+type wsFrame struct {
+	Conn int `json:"conn"`
+	Frame
+}
+
+// LoadCassette reads a cassette written by a Recorder
+func LoadCassette(r io.Reader) (*Cassette, error) {
+	c := &Cassette{}
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var line cassetteLine
+		if err := dec.Decode(&line); err != nil {
+			if err == io.EOF {
+				return c, nil
+			}
+			return nil, fmt.Errorf("invalid cassette: %w", err)
+		}
+		switch {
+		case line.HTTP != nil:
+			c.Interactions = append(c.Interactions, *line.HTTP)
+		case line.Dial != nil:
+			if line.Dial.Conn != len(c.WebSockets) {
+				return nil, fmt.Errorf("invalid cassette: unexpected connection %d", line.Dial.Conn)
+			}
+			c.WebSockets = append(c.WebSockets, WebSocket{URL: line.Dial.URL})
+		case line.WS != nil:
+			if line.WS.Conn < 0 || line.WS.Conn >= len(c.WebSockets) {
+				return nil, fmt.Errorf("invalid cassette: unknown connection %d", line.WS.Conn)
+			}
+			ws := &c.WebSockets[line.WS.Conn]
+			ws.Frames = append(ws.Frames, line.WS.Frame)
+		}
+	}
+}
+
+// LoadCassetteFile reads the cassette at path
+func LoadCassetteFile(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadCassette(f)
+}
+
+// Recorder writes the requests and responses of a client, including the
+// messages of the login websocket, to a cassette as they happen, so a run
+// ending in failure is recorded up to the failure. The values of the cookies
+// set by the upstream are redacted, but the cassette still contains the pages
+// seen by the account.
+type Recorder struct {
+	mtx      sync.Mutex
+	enc      *json.Encoder
+	nextConn int
+	err      error
+}
+
+// NewRecorder creates a recorder writing the cassette to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error writing the cassette
+func (r *Recorder) Err() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.err
+}
+
+func (r *Recorder) write(line cassetteLine) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(line)
+	}
+}
+
+// Transport returns a transport sending the requests with next and recording
+// them
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{r, next}
+}
+
+type recordingTransport struct {
+	rec  *Recorder
+	next http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	it := Interaction{Method: req.Method, URL: req.URL.String()}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		it.Err = err.Error()
+		t.rec.write(cassetteLine{HTTP: &it})
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		it.Err = err.Error()
+		t.rec.write(cassetteLine{HTTP: &it})
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	it.Status = resp.StatusCode
+	it.Header = redactCookies(resp.Header)
+	it.Body = string(body)
+	t.rec.write(cassetteLine{HTTP: &it})
+	return resp, nil
+}
+
+// redactCookies returns a copy of the header with the values of the cookies
+// replaced
+func redactCookies(h http.Header) http.Header {
+	h = h.Clone()
+	for i, c := range h.Values("Set-Cookie") {
+		name, rest, _ := strings.Cut(c, "=")
+		_, attrs, hasAttrs := strings.Cut(rest, ";")
+		c = name + "=" + kRedacted
+		if hasAttrs {
+			c += ";" + attrs
+		}
+		h["Set-Cookie"][i] = c
+	}
+	return h
+}
+
+// dialer returns a function connecting with d and recording the messages
+func (r *Recorder) dialer(d *websocket.Dialer) func(ctx context.Context, u string) (wsConn, error) {
+	return func(ctx context.Context, u string) (wsConn, error) {
+		conn, _, err := d.DialContext(ctx, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.mtx.Lock()
+		id := r.nextConn
+		r.nextConn++
+		r.mtx.Unlock()
+		r.write(cassetteLine{Dial: &wsDial{Conn: id, URL: u}})
+		return &recordingConn{conn, r, id}, nil
+	}
+}
+
+type recordingConn struct {
+	*websocket.Conn
+	rec *Recorder
+	id  int
+}
+
+func (c *recordingConn) ReadJSON(v interface{}) error {
+	typ, data, err := c.Conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	c.rec.write(cassetteLine{WS: &wsFrame{c.id, Frame{Type: typ, Data: string(data)}}})
+	return json.Unmarshal(data, v)
+}
+
+func (c *recordingConn) WriteMessage(typ int, data []byte) error {
+	c.rec.write(cassetteLine{WS: &wsFrame{c.id, Frame{Sent: true, Type: typ, Data: string(data)}}})
+	return c.Conn.WriteMessage(typ, data)
+}
+
+// Replayer serves the responses of a cassette in place of the upstream. A
+// request is answered with the first unused interaction of the same method,
+// path and query, ignoring the timestamps in the query, so concurrent requests
+// are replayed deterministically. The websocket connections are replayed in
+// the order they are recorded.
+type Replayer struct {
+	mtx      sync.Mutex
+	cassette *Cassette
+	used     []bool
+	nextConn int
+}
+
+// NewReplayer creates a replayer of the cassette
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{
+		cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}
+}
+
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	it, err := p.take(req.Method, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if it.Err != "" {
+		return nil, errors.New(it.Err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Status, http.StatusText(it.Status)),
+		StatusCode:    it.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        it.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(it.Body)),
+		ContentLength: int64(len(it.Body)),
+		Request:       req,
+	}, nil
+}
+
+// take returns the first unused interaction matching the request
+func (p *Replayer) take(method string, u *url.URL) (Interaction, error) {
+	key := replayKey(method, u)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for i, it := range p.cassette.Interactions {
+		if p.used[i] {
+			continue
+		}
+		recorded, err := url.Parse(it.URL)
+		if err != nil || replayKey(it.Method, recorded) != key {
+			continue
+		}
+		p.used[i] = true
+		return it, nil
+	}
+	// the caller reports the url of the request
+	return Interaction{}, ErrNotRecorded
+}
+
+// replayKey identifies the requests to the same resource, the timestamps and
+// the random numbers in the query are ignored as they change on each run
+func replayKey(method string, u *url.URL) string {
+	q := u.Query()
+	q.Del("_")
+	q.Del("seed")
+	q.Del("tid")
+	return method + " " + u.Path + "?" + q.Encode()
+}
+
+func (p *Replayer) dial(ctx context.Context, u string) (wsConn, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.nextConn >= len(p.cassette.WebSockets) {
+		return nil, fmt.Errorf("%w: websocket %s", ErrNotRecorded, u)
+	}
+	ws := p.cassette.WebSockets[p.nextConn]
+	p.nextConn++
+	conn := &replayConn{}
+	for _, f := range ws.Frames {
+		if !f.Sent {
+			conn.frames = append(conn.frames, f)
+		}
+	}
+	return conn, nil
+}
+
+// replayConn replays the messages received on a recorded connection, the
+// messages sent by the client are discarded. Reading past the recorded
+// messages fails as if the upstream closed the connection.
+type replayConn struct {
+	mtx      sync.Mutex
+	frames   []Frame
+	closed   bool
+	deadline bool // set once the read deadline has passed
+}
+
+func (c *replayConn) ReadJSON(v interface{}) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	switch {
+	case c.closed:
+		return net.ErrClosed
+	case c.deadline:
+		return os.ErrDeadlineExceeded
+	case len(c.frames) == 0:
+		return &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	}
+	f := c.frames[0]
+	c.frames = c.frames[1:]
+	return json.Unmarshal([]byte(f.Data), v)
+}
+
+func (c *replayConn) WriteMessage(typ int, data []byte) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return nil
+}
+
+func (c *replayConn) SetReadDeadline(t time.Time) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.deadline = !t.IsZero() && !t.After(time.Now())
+	return nil
+}
+
+func (c *replayConn) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.closed = true
+	return nil
+}
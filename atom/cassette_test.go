@@ -0,0 +1,125 @@
+package atom
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplayKey(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		url    string
+		want   string
+	}{
+		{"GET", "https://example.com/a/b", "GET /a/b?"},
+		{"GET", "https://example.com/a?_=1&seed=0.5&tid=3", "GET /a?"},
+		{"POST", "https://example.com/a?b=2&a=1&_=1", "POST /a?a=1&b=2"},
+		{"GET", "http://other.com/a?x=%E4%B8%9C", "GET /a?x=%E4%B8%9C"},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := replayKey(tc.method, u); got != tc.want {
+			t.Errorf("replayKey(%s, %s) = %q, want %q", tc.method, tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestLoadCassette(t *testing.T) {
+	c, err := LoadCassette(strings.NewReader(`{"http":{"method":"GET","url":"https://example.com/a","status":200,"body":"ok"}}
+{"dial":{"conn":0,"url":"wss://example.com/ws"}}
+{"ws":{"conn":0,"sent":true,"type":1,"data":"hello"}}
+{"http":{"method":"GET","url":"https://example.com/b","err":"EOF"}}
+{"ws":{"conn":0,"type":1,"data":"world"}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Cassette{
+		Interactions: []Interaction{
+			{Method: "GET", URL: "https://example.com/a", Status: 200, Body: "ok"},
+			{Method: "GET", URL: "https://example.com/b", Err: "EOF"},
+		},
+		WebSockets: []WebSocket{{
+			URL: "wss://example.com/ws",
+			Frames: []Frame{
+				{Sent: true, Type: 1, Data: "hello"},
+				{Type: 1, Data: "world"},
+			},
+		}},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Fatalf("got %+v, want %+v", c, want)
+	}
+}
+
+func TestLoadCassetteInvalid(t *testing.T) {
+	for _, s := range []string{
+		`{"http":`,
+		`{"dial":{"conn":1,"url":"wss://example.com/ws"}}`,
+		`{"ws":{"conn":0,"type":1,"data":"hello"}}`,
+	} {
+		if _, err := LoadCassette(strings.NewReader(s)); err == nil {
+			t.Errorf("%s: invalid cassette accepted", s)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "auth", Value: "secret"})
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	hc := &http.Client{Transport: rec.Transport(http.DefaultTransport)}
+	for _, p := range []string{"/a?_=1", "/b", "/a?_=2"} {
+		resp, err := hc.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Fatal("cookie value is recorded")
+	}
+
+	c, err := LoadCassette(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 3 {
+		t.Fatalf("got %d interactions, want 3", len(c.Interactions))
+	}
+	srv.Close()
+
+	hc = &http.Client{Transport: NewReplayer(c)}
+	// the query params changing on each run are ignored
+	for _, p := range []string{"/b", "/a?_=3", "/a?_=4"} {
+		resp, err := hc.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, _, _ := strings.Cut(p, "?"); string(body) != want {
+			t.Errorf("%s: got body %q", p, body)
+		}
+	}
+	if _, err := hc.Get(srv.URL + "/a"); err == nil {
+		t.Error("replayed a request more times than recorded")
+	}
+}
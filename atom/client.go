@@ -1,10 +1,13 @@
 package atom
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -13,16 +16,20 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/alexshen/juweitong/atom/parse"
 	"github.com/go-resty/resty/v2"
 	"github.com/gorilla/websocket"
 	"github.com/samber/lo"
 )
 
 const (
-	kDomain         = "www.juweitong.cn"
-	kBaseUrl        = "https://" + kDomain + "/neighbour"
-	kClientProtocol = "2.1"
+	kDomain           = "www.juweitong.cn"
+	kBaseUrl          = "https://" + kDomain + "/neighbour"
+	kWebSocketBaseUrl = "wss://" + kDomain + "/neighbour"
+	kClientProtocol   = "2.1"
+	kDefaultPageSize  = 10
+	kLoginPagePath    = "/home/login"
+	kLoginPageMarker  = `id="qrLogin"`
 )
 
 const (
@@ -34,6 +41,10 @@ const (
 var (
 	ErrQRLoginAlreadyStarted = errors.New("qr login already started")
 	ErrNotLoggedIn           = errors.New("not logged in")
+	ErrSessionExpired        = errors.New("session expired")
+	ErrCommunityHeld         = errors.New("community is held by WithCommunity")
+	ErrUnknownCommunity      = errors.New("unknown community")
+	ErrInvalidCount          = errors.New("post count must be positive")
 )
 
 type Community struct {
@@ -44,6 +55,8 @@ type Community struct {
 type LikedPost struct {
 	MemberId string
 	PostId   string
+	LikedAt  time.Time // zero if unknown
+	ByClient bool      // false if the post was found liked already
 }
 
 type LikedPostsHistory interface {
@@ -62,14 +75,30 @@ func (o NullLikedPostsHistory) Add(post LikedPost) error {
 }
 
 type Client struct {
-	id           string
-	communities  []Community
-	state        atomic.Int32
-	httpclient   *resty.Client
-	loginConn    *websocket.Conn
-	loginDone    chan struct{}
+	id          string
+	communities []Community
+	state       atomic.Int32
+	httpclient  *resty.Client
+	// mtx guards the login and the communities
+	mtx          sync.Mutex
+	loginDone    chan struct{} // closed when the login ends, nil if not logging in
+	cancelLogin  context.CancelFunc
+	onLogin      LoginHandler // handler of the last login, notified of the session expiry
 	curCommunity int
-	history      LikedPostsHistory
+	// commMtx is held shared by the operations on the current community, and
+	// exclusively by switching the community
+	commMtx     sync.RWMutex
+	history     LikedPostsHistory
+	baseUrl     string
+	wsBaseUrl   string
+	qrTimeout   time.Duration
+	inFlight    chan struct{} // semaphore of posts being liked, nil if unlimited
+	limiter     *RateLimiter  // nil if unlimited
+	retryPolicy RetryPolicy
+	dialWS      func(ctx context.Context, u string) (wsConn, error)
+	dryRun      bool
+	filter      atomic.Pointer[Filter] // nil if liking all posts
+	opts        clientOptions          // for creating clients with the same options
 }
 
 type negotiationResult struct {
@@ -77,57 +106,78 @@ type negotiationResult struct {
 	ConnectionId    string
 }
 
-type LoginHandler func()
+// LoginEventKind tells the stage of a qr login
+type LoginEventKind int
 
-type likePostConfig struct {
-	viewPostApiPath string
-	listPostApiPath string
-	favText         string            // text of the like button
-	listPostParams  map[string]string // query params for getting list of posts
+const (
+	// LoginQRScanned is fired when the qr code has been scanned and the login
+	// is being confirmed
+	LoginQRScanned LoginEventKind = iota
+	// LoginQRExpired is fired when the qr code expired before being scanned,
+	// a new login has to be started
+	LoginQRExpired
+	// LoginFailed is fired when the login was rejected or aborted, see
+	// LoginEvent.Err for the reason
+	LoginFailed
+	// LoggedIn is fired when the login succeeded
+	LoggedIn
+	// LoginSessionExpired is fired when the upstream rejects the session of
+	// a logged in client, a new login has to be started
+	LoginSessionExpired
+)
+
+func (k LoginEventKind) String() string {
+	switch k {
+	case LoginQRScanned:
+		return "scanned"
+	case LoginQRExpired:
+		return "expired"
+	case LoginFailed:
+		return "failed"
+	case LoggedIn:
+		return "loggedin"
+	case LoginSessionExpired:
+		return "session_expired"
+	}
+	return fmt.Sprintf("LoginEventKind(%d)", int(k))
 }
 
-type post struct {
-	viewId string // the id for reading
-	likeId string // the id for liking
+// LoginEvent reports the progress of a qr login
+type LoginEvent struct {
+	Kind LoginEventKind
+	Err  error // set if Kind is LoginFailed or LoginSessionExpired
 }
 
-var (
-	noticeConfig = likePostConfig{
-		"/community/title_view?title=",
-		"/community/notice_list_more",
-		"点赞",
-		map[string]string{"condtion": `{"sortCondition":"1","partCondition":""}`},
-	}
-	momentsConfig = likePostConfig{
-		"/community/around_view?title=",
-		"/community/around_help_list_more",
-		"点赞",
-		map[string]string{"condition": `{"tag":"","little":"","sortCondition":""}`},
-	}
-	ccpNoticeConfig = likePostConfig{
-		"/community/ccp_view?title=",
-		"/community/ccp_list_more",
-		"点赞",
-		map[string]string{
-			"category":  "80",
-			"condition": "{}",
-		},
-	}
-	proposalConfig = likePostConfig{
-		"/community/proposal_view?caseId=",
-		"/community/proposal_list_more",
-		"赞成",
-		map[string]string{"condition": "{}"},
-	}
-)
+// LoginHandler is called from the login goroutine for each login event. No
+// more events are fired after LoginQRExpired, LoginFailed or LoggedIn, except
+// LoginSessionExpired which is fired from the request finding the session
+// expired.
+type LoginHandler func(e LoginEvent)
+
+// ListOptions controls how posts are fetched page by page. Fetching stops
+// at the end of the list or when any of the conditions is met.
+type ListOptions struct {
+	// PageSize is the number of posts requested per page, 10 if not set
+	PageSize int
+	// MaxPosts stops fetching after that many posts, 0 for no limit
+	MaxPosts int
+	// Since stops fetching at the first post published before it, ignored if
+	// it is the zero time. Posts of unknown time are kept, but ErrParse is
+	// returned if no post of a page shows its time, as the upstream markup of
+	// the time is not confirmed and Since would never stop the fetching.
+	Since time.Time
+	// StopAfterKnown stops fetching after a run of that many consecutive
+	// posts found in the history, 0 disables the check
+	StopAfterKnown int
+}
 
 func get(req *resty.Request, url string) (*resty.Response, error) {
 	resp, err := req.Get(url)
 	if err == nil && !resp.IsSuccess() {
-		path, _ := strings.CutPrefix(resp.Request.URL, kBaseUrl)
-		err = fmt.Errorf("%s: %s", path, resp.Status())
+		path, _, _ := strings.Cut(url, "?")
+		err = &UpstreamStatusError{path, resp.StatusCode(), resp.Status()}
 	}
-	return resp, nil
+	return resp, err
 }
 
 func getWithJsonError(req *resty.Request, url string) (*resty.Response, error) {
@@ -148,45 +198,138 @@ func getWithJsonError(req *resty.Request, url string) (*resty.Response, error) {
 
 // NewClient creates a client with the DAO. A DAO is used for speeding up
 // the liking process by ignoring already liked posts.
-func NewClient(history LikedPostsHistory) *Client {
+func NewClient(history LikedPostsHistory, opts ...Option) *Client {
+	o := clientOptions{
+		baseUrl:     kBaseUrl,
+		retryPolicy: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return newClient(history, o)
+}
+
+func newClient(history LikedPostsHistory, o clientOptions) *Client {
 	c := &Client{
-		httpclient:   resty.New(),
+		httpclient:   o.newRestyClient(),
 		curCommunity: -1,
 		history:      history,
+		baseUrl:      o.baseUrl,
+		wsBaseUrl:    o.webSocketBaseUrl(),
+		qrTimeout:    o.qrTimeout,
+		limiter:      o.limiter,
+		retryPolicy:  o.retryPolicy,
+		dialWS:       o.webSocketDialer(),
+		dryRun:       o.dryRun,
+		opts:         o,
 	}
-	c.httpclient.SetBaseURL(kBaseUrl)
+	c.filter.Store(o.filter)
+	if o.maxInFlight > 0 {
+		c.inFlight = make(chan struct{}, o.maxInFlight)
+	}
+	c.httpclient.SetBaseURL(o.baseUrl)
+	c.httpclient.OnAfterResponse(c.checkSession)
+	c.installObservers(o.observers)
 	return c
 }
 
+// checkSession fails the responses showing the login page, which the upstream
+// redirects to once the session expires. A logged in client is logged out.
+func (cli *Client) checkSession(_ *resty.Client, resp *resty.Response) error {
+	if !isLoginPage(resp) {
+		return nil
+	}
+	if cli.state.CompareAndSwap(kStateLoggedIn, kStateLoggedOut) {
+		cli.mtx.Lock()
+		onLogin := cli.onLogin
+		cli.mtx.Unlock()
+		if onLogin != nil {
+			onLogin(LoginEvent{Kind: LoginSessionExpired, Err: ErrSessionExpired})
+		}
+	}
+	return ErrSessionExpired
+}
+
+func isLoginPage(resp *resty.Response) bool {
+	// the redirect is not followed if the http client is told not to
+	if resp.StatusCode() == http.StatusFound &&
+		strings.HasSuffix(resp.Header().Get("Location"), kLoginPagePath) {
+		return true
+	}
+	if raw := resp.RawResponse; raw != nil && raw.Request != nil &&
+		strings.HasSuffix(raw.Request.URL.Path, kLoginPagePath) {
+		return true
+	}
+	return bytes.Contains(resp.Body(), []byte(kLoginPageMarker))
+}
+
 func (cli *Client) SetTimeout(d time.Duration) {
 	cli.httpclient.SetTimeout(d)
 }
 
+// SetFilter replaces the filter deciding which posts to like, nil to like all
+// posts
+func (cli *Client) SetFilter(f *Filter) {
+	cli.filter.Store(f)
+}
+
+// DryRun returns true if the client is created with WithDryRun
+func (cli *Client) DryRun() bool {
+	return cli.dryRun
+}
+
 // StartQRLogin starts the qr login process and returns the url of the qr code.
 // If the login already started, ErrQRLoginAlreadyStarted is returned
 func (cli *Client) StartQRLogin(onLogin LoginHandler) (string, error) {
-	if cli.state.Load() == kStateScanQRCode {
+	return cli.StartQRLoginContext(context.Background(), onLogin)
+}
+
+// StartQRLoginContext is like StartQRLogin but the whole login process,
+// including waiting for the qr code to be scanned, is bound to ctx.
+// Cancelling ctx has the same effect as calling StopQRLogin.
+func (cli *Client) StartQRLoginContext(ctx context.Context, onLogin LoginHandler) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	cli.mtx.Lock()
+	if cli.loginDone != nil {
+		cli.mtx.Unlock()
+		cancel()
 		return "", ErrQRLoginAlreadyStarted
 	}
+	cli.loginDone = done
+	cli.cancelLogin = cancel
+	cli.onLogin = onLogin
+	cli.mtx.Unlock()
 
-	negot, err := cli.negotiate()
+	negot, err := cli.negotiate(ctx)
 	if err != nil {
+		cli.endLogin(done)
 		return "", err
 	}
 
-	conn, err := cli.createLoginConnection(negot.ConnectionToken)
+	conn, err := cli.createLoginConnection(ctx, negot.ConnectionToken)
 	if err != nil {
+		cli.endLogin(done)
 		return "", err
 	}
 
-	cli.loginConn = conn
-	return cli.doQRLogin(negot.ConnectionId, onLogin)
+	return cli.doQRLogin(ctx, cancel, done, conn, negot.ConnectionId, onLogin)
 }
 
-func (cli *Client) negotiate() (negotiationResult, error) {
+// endLogin marks the login ended
+func (cli *Client) endLogin(done chan struct{}) {
+	cli.mtx.Lock()
+	cli.cancelLogin()
+	cli.loginDone = nil
+	cli.cancelLogin = nil
+	cli.mtx.Unlock()
+	close(done)
+}
+
+func (cli *Client) negotiate(ctx context.Context) (negotiationResult, error) {
 	var negot negotiationResult
 	_, err := get(
-		cli.httpclient.R().
+		cli.r(ctx, OpNegotiate).
 			SetQueryParams(map[string]string{
 				"clientProtocol": kClientProtocol,
 				"_":              strconv.FormatInt(time.Now().UnixMilli(), 10),
@@ -196,7 +339,15 @@ func (cli *Client) negotiate() (negotiationResult, error) {
 	return negot, err
 }
 
-func (cli *Client) createLoginConnection(token string) (*websocket.Conn, error) {
+// wsConn is the websocket connection of the qr login
+type wsConn interface {
+	ReadJSON(v interface{}) error
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+func (cli *Client) createLoginConnection(ctx context.Context, token string) (wsConn, error) {
 	// start the websocket connection
 	opts := url.Values{}
 	opts.Set("clientProtocol", "2.1")
@@ -204,8 +355,8 @@ func (cli *Client) createLoginConnection(token string) (*websocket.Conn, error)
 	opts.Set("connectionToken", token)
 	opts.Set("tid", strconv.Itoa(int(rand.Float32()*11)))
 
-	u := "wss://" + kDomain + "/neighbour/authorize/connect?" + opts.Encode()
-	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	u := cli.wsBaseUrl + "/authorize/connect?" + opts.Encode()
+	conn, err := cli.dialWS(ctx, u)
 	if err != nil {
 		return nil, err
 	}
@@ -214,7 +365,7 @@ func (cli *Client) createLoginConnection(token string) (*websocket.Conn, error)
 	opts.Add("_", strconv.FormatInt(time.Now().UnixMilli(), 10))
 
 	_, err = get(
-		cli.httpclient.R(),
+		cli.r(ctx, OpLogin),
 		"/authorize/start?"+opts.Encode())
 	if err != nil {
 		conn.Close()
@@ -224,16 +375,40 @@ func (cli *Client) createLoginConnection(token string) (*websocket.Conn, error)
 	return conn, err
 }
 
-func (cli *Client) doQRLogin(id string, onLogin LoginHandler) (string, error) {
+func (cli *Client) doQRLogin(ctx context.Context, cancel context.CancelFunc, done chan struct{},
+	conn wsConn, id string, onLogin LoginHandler) (string, error) {
 	type qrcodeResponse struct {
 		err error
 		url string
 	}
-	cli.loginDone = make(chan struct{})
 	initDone := make(chan qrcodeResponse)
 
+	// unblock the pending read once the login is cancelled
 	go func() {
-		err := cli.loginConn.WriteMessage(websocket.TextMessage, []byte("qr"))
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		defer func() {
+			// the login was aborted before the qr code was scanned
+			cli.state.CompareAndSwap(kStateScanQRCode, kStateLoggedOut)
+			conn.Close()
+			cli.endLogin(done)
+		}()
+		emit := func(e LoginEvent) {
+			if onLogin != nil {
+				onLogin(e)
+			}
+		}
+		// set when the qr code is not scanned within the timeout
+		var timedOut atomic.Bool
+
+		err := conn.WriteMessage(websocket.TextMessage, []byte("qr"))
 		if err != nil {
 			initDone <- qrcodeResponse{err: err}
 			return
@@ -251,20 +426,29 @@ func (cli *Client) doQRLogin(id string, onLogin LoginHandler) (string, error) {
 		var scanning bool
 		for {
 			var resp response
-			err := cli.loginConn.ReadJSON(&resp)
+			err := conn.ReadJSON(&resp)
 			if err != nil {
 				if !scanning {
 					initDone <- qrcodeResponse{err: err}
-				} else {
 					break
 				}
+				switch {
+				case timedOut.Load() || errors.Is(ctx.Err(), context.DeadlineExceeded):
+					emit(LoginEvent{Kind: LoginQRExpired})
+				case ctx.Err() != nil:
+					emit(LoginEvent{Kind: LoginFailed, Err: ctx.Err()})
+				default:
+					// the upstream closes the connection once the qr code expires
+					emit(LoginEvent{Kind: LoginQRExpired})
+				}
+				break
 			}
 			if len(resp.M) == 0 {
 				continue
 			}
 			if resp.M[0].Init {
 				resp, err := get(
-					cli.httpclient.R().SetQueryParam("id", id),
+					cli.r(ctx, OpLogin).SetQueryParam("id", id),
 					"/home/qr_login_more_v1")
 				if err != nil {
 					initDone <- qrcodeResponse{err: err}
@@ -273,37 +457,50 @@ func (cli *Client) doQRLogin(id string, onLogin LoginHandler) (string, error) {
 
 				cli.state.Store(kStateScanQRCode)
 				scanning = true
-				initDone <- qrcodeResponse{
-					url: regexp.MustCompile("\"([^\"]+)").FindStringSubmatch(resp.String())[1],
+				if cli.qrTimeout > 0 {
+					timer := time.AfterFunc(cli.qrTimeout, func() {
+						timedOut.Store(true)
+						cancel()
+					})
+					defer timer.Stop()
+				}
+				m := regexp.MustCompile("\"([^\"]+)").FindStringSubmatch(resp.String())
+				if m == nil {
+					initDone <- qrcodeResponse{err: &ParseError{Err: errors.New("qr code url not found")}}
+					break
 				}
+				initDone <- qrcodeResponse{url: m[1]}
 			} else if resp.M[0].BindUser {
+				emit(LoginEvent{Kind: LoginQRScanned})
 				_, err := get(
-					cli.httpclient.R().SetQueryParam("id", id),
+					cli.r(ctx, OpLogin).SetQueryParam("id", id),
 					"/home/qr_login_do")
 				if err != nil {
-					log.Printf("qr_login_do: %v", err)
+					err = fmt.Errorf("qr_login_do: %w", err)
+				}
+				if err == nil {
+					err = cli.updateCommunities(ctx)
+				}
+				if err == nil {
+					err = cli.updateCurrentCommunity(ctx)
+				}
+				if err != nil {
 					cli.state.Store(kStateLoggedOut)
+					emit(LoginEvent{Kind: LoginFailed, Err: err})
 				} else {
-					cli.updateCommunities()
-					cli.updateCurrentCommunity()
 					cli.state.Store(kStateLoggedIn)
-					if onLogin != nil {
-						onLogin()
-					}
+					emit(LoginEvent{Kind: LoggedIn})
 				}
 				break
 			}
 		}
-
-		cli.loginConn.Close()
-		close(cli.loginDone)
 	}()
 
 	res := <-initDone
 	return res.url, res.err
 }
 
-func (cli *Client) updateCommunities() {
+func (cli *Client) updateCommunities(ctx context.Context) error {
 	type binding struct {
 		CommunityName string `json:"community_name"`
 		Status        string `json:"status"`
@@ -314,54 +511,70 @@ func (cli *Client) updateCommunities() {
 		Binds []binding `json:"binds"`
 	}
 	_, err := get(
-		cli.httpclient.R().
+		cli.r(ctx, OpCommunities).
 			SetQueryParam("seed", strconv.FormatInt(time.Now().UnixMilli(), 10)).
 			SetQueryParam("wxid", "").
 			SetResult(&res),
 		"/api/register/member/bind")
 	if err != nil {
-		log.Println(err)
-		return
+		return fmt.Errorf("failed to get communities: %w", err)
 	}
-	cli.id = res.Id
-	cli.communities = lo.FilterMap(res.Binds, func(e binding, i int) (Community, bool) {
+	communities := lo.FilterMap(res.Binds, func(e binding, i int) (Community, bool) {
 		if e.Status != "已通过" {
 			return Community{}, false
 		}
 		return Community{e.CommunityName, e.Member}, true
 	})
-
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
+	cli.id = res.Id
+	cli.communities = communities
+	return nil
 }
 
-func (cli *Client) updateCurrentCommunity() {
-	resp, err := get(cli.httpclient.R(), "/home/home")
+func (cli *Client) updateCurrentCommunity(ctx context.Context) error {
+	curCommName, err := cli.currentCommunityName(ctx)
 	if err != nil {
-		log.Print(err)
-		return
+		return fmt.Errorf("failed to get current community: %w", err)
 	}
-	if !resp.IsSuccess() {
-		log.Printf("get home: %s", resp.Status())
-		return
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
+	cli.curCommunity = cli.communityIndexByNameNoLock(curCommName)
+	return nil
+}
+
+// currentCommunityName returns the name of the current community shown on
+// the home page. ErrSessionExpired is returned if the login page is shown in
+// place of the home page, ErrParse if the home page cannot be parsed.
+func (cli *Client) currentCommunityName(ctx context.Context) (string, error) {
+	resp, err := get(cli.r(ctx, OpHome), "/home/home")
+	if err != nil {
+		return "", err
 	}
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(resp.String()))
+	home, err := parse.Home(bytes.NewReader(resp.Body()))
 	if err != nil {
-		log.Print(err)
-		return
+		return "", err
 	}
+	return home.Community, nil
+}
 
-	curCommName := doc.Find("#changeMember span").First().Text()
-	_, cli.curCommunity, _ = lo.FindIndexOf(cli.communities, func(e Community) bool {
-		return e.Name == curCommName
+func (cli *Client) communityIndexByNameNoLock(name string) int {
+	_, i, _ := lo.FindIndexOf(cli.communities, func(e Community) bool {
+		return e.Name == name
 	})
+	return i
 }
 
 func (cli *Client) StopQRLogin() {
-	if cli.state.Load() != kStateScanQRCode {
+	cli.mtx.Lock()
+	done, cancel := cli.loginDone, cli.cancelLogin
+	cli.mtx.Unlock()
+	if done == nil {
 		return
 	}
 
-	cli.loginConn.SetReadDeadline(time.Now())
-	<-cli.loginDone
+	cancel()
+	<-done
 }
 
 func (cli *Client) IsLoggedIn() bool {
@@ -369,39 +582,69 @@ func (cli *Client) IsLoggedIn() bool {
 }
 
 func (cli *Client) Communities() []Community {
-	return cli.communities
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
+	return append([]Community(nil), cli.communities...)
 }
 
 // SetCurrentCommunity sets the current community at the given index
 func (cli *Client) SetCurrentCommunity(i int) error {
+	return cli.SetCurrentCommunityContext(context.Background(), i)
+}
+
+func (cli *Client) SetCurrentCommunityContext(ctx context.Context, i int) error {
 	if err := cli.ensureLoggedIn(); err != nil {
 		return err
 	}
-	_, err := get(cli.httpclient.R().
+	if cli.holdsCommunity(ctx) {
+		return ErrCommunityHeld
+	}
+	communities := cli.Communities()
+	if i < 0 || i >= len(communities) {
+		return fmt.Errorf("%w: index %d", ErrUnknownCommunity, i)
+	}
+	cli.commMtx.Lock()
+	defer cli.commMtx.Unlock()
+	return cli.switchCommunity(ctx, i, communities[i].MemberId)
+}
+
+// switchCommunity switches to the community, commMtx must be held exclusively
+func (cli *Client) switchCommunity(ctx context.Context, i int, memberId string) error {
+	_, err := get(cli.r(ctx, OpSwitch).
 		SetQueryParam("seed", strconv.FormatInt(time.Now().UnixMilli(), 10)),
-		"/api/member/switch/"+cli.communities[i].MemberId)
+		"/api/member/switch/"+memberId)
 	if err != nil {
 		return err
 	}
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
 	cli.curCommunity = i
 	return nil
 }
 
 func (cli *Client) SetCurrentCommunityById(id string) error {
+	return cli.SetCurrentCommunityByIdContext(context.Background(), id)
+}
+
+func (cli *Client) SetCurrentCommunityByIdContext(ctx context.Context, id string) error {
 	_, index, _ := lo.FindIndexOf(cli.Communities(), func(e Community) bool {
 		return e.MemberId == id
 	})
 	if index == -1 {
-		return fmt.Errorf("invalid member id: %s", id)
+		return fmt.Errorf("%w: %s", ErrUnknownCommunity, id)
 	}
-	return cli.SetCurrentCommunity(index)
+	return cli.SetCurrentCommunityContext(ctx, index)
 }
 
 func (cli *Client) CurrentCommunityIndex() int {
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
 	return cli.curCommunity
 }
 
 func (cli *Client) CurrentCommunity() Community {
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
 	if cli.curCommunity == -1 {
 		return Community{}
 	}
@@ -409,158 +652,429 @@ func (cli *Client) CurrentCommunity() Community {
 }
 
 func (cli *Client) GetCommunityById(id string) (Community, bool) {
-	return lo.Find(cli.communities, func(e Community) bool {
+	return lo.Find(cli.Communities(), func(e Community) bool {
 		return e.MemberId == id
 	})
 }
 
 func (cli *Client) Id() string {
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
 	return cli.id
 }
 
-// LikeNotices visits count of the latest notices and returns the number of
-// posts that have been liked
-func (cli *Client) LikeNotices(count int) int {
+// WithCommunity switches to the community with the member id and calls f with
+// the community held, i.e. the current community cannot be switched until f
+// returns. Operations in f must be called with the ctx passed to f, other
+// operations on the current community wait until f returns.
+func (cli *Client) WithCommunity(ctx context.Context, id string, f func(ctx context.Context) error) error {
 	if err := cli.ensureLoggedIn(); err != nil {
-		return 0
+		return err
+	}
+	if cli.holdsCommunity(ctx) {
+		return ErrCommunityHeld
+	}
+	_, index, _ := lo.FindIndexOf(cli.Communities(), func(e Community) bool {
+		return e.MemberId == id
+	})
+	if index == -1 {
+		return fmt.Errorf("%w: %s", ErrUnknownCommunity, id)
 	}
 
-	posts, err := cli.getPosts(
-		noticeConfig.listPostApiPath,
-		noticeConfig.listPostParams,
-		count)
-	if err != nil {
-		log.Print(err)
-		return 0
+	cli.commMtx.Lock()
+	defer cli.commMtx.Unlock()
+	if err := cli.switchCommunity(ctx, index, id); err != nil {
+		return err
 	}
-	return cli.likePosts(posts, noticeConfig)
+	return f(context.WithValue(ctx, heldCommunityKey{}, cli))
 }
 
-func (cli *Client) LikeMoments(count int) int {
-	if err := cli.ensureLoggedIn(); err != nil {
-		return 0
-	}
+// heldCommunityKey is the context key of the client holding the community
+type heldCommunityKey struct{}
 
-	posts, err := cli.getPosts(
-		momentsConfig.listPostApiPath,
-		momentsConfig.listPostParams,
-		count)
-	if err != nil {
-		log.Print(err)
-		return 0
-	}
-	return cli.likePosts(posts, momentsConfig)
+func (cli *Client) holdsCommunity(ctx context.Context) bool {
+	return ctx.Value(heldCommunityKey{}) == cli
 }
 
-func (cli *Client) LikeCCPPosts(count int) int {
-	if err := cli.ensureLoggedIn(); err != nil {
-		return 0
+// holdCommunity keeps the current community from being switched until the
+// returned function is called
+func (cli *Client) holdCommunity(ctx context.Context) func() {
+	if cli.holdsCommunity(ctx) {
+		// held exclusively by WithCommunity
+		return func() {}
 	}
+	cli.commMtx.RLock()
+	return cli.commMtx.RUnlock
+}
 
-	posts, err := cli.getPosts(
-		ccpNoticeConfig.listPostApiPath,
-		ccpNoticeConfig.listPostParams,
-		count)
-	if err != nil {
-		log.Print(err)
-		return 0
+// LikeNotices visits count of the latest notices and returns the report of
+// the visited posts
+func (cli *Client) LikeNotices(count int) (*LikeReport, error) {
+	return cli.LikeNoticesContext(context.Background(), count)
+}
+
+func (cli *Client) LikeNoticesContext(ctx context.Context, count int) (*LikeReport, error) {
+	return cli.Like(ctx, KindNotices, count)
+}
+
+func (cli *Client) LikeNoticesPaged(ctx context.Context, opts ListOptions) (*LikeReport, error) {
+	return cli.LikePaged(ctx, KindNotices, opts)
+}
+
+func (cli *Client) LikeMoments(count int) (*LikeReport, error) {
+	return cli.LikeMomentsContext(context.Background(), count)
+}
+
+func (cli *Client) LikeMomentsContext(ctx context.Context, count int) (*LikeReport, error) {
+	return cli.Like(ctx, KindMoments, count)
+}
+
+func (cli *Client) LikeMomentsPaged(ctx context.Context, opts ListOptions) (*LikeReport, error) {
+	return cli.LikePaged(ctx, KindMoments, opts)
+}
+
+func (cli *Client) LikeCCPPosts(count int) (*LikeReport, error) {
+	return cli.LikeCCPPostsContext(context.Background(), count)
+}
+
+func (cli *Client) LikeCCPPostsContext(ctx context.Context, count int) (*LikeReport, error) {
+	return cli.Like(ctx, KindCCPPosts, count)
+}
+
+func (cli *Client) LikeCCPPostsPaged(ctx context.Context, opts ListOptions) (*LikeReport, error) {
+	return cli.LikePaged(ctx, KindCCPPosts, opts)
+}
+
+func (cli *Client) LikeProposals(count int) (*LikeReport, error) {
+	return cli.LikeProposalsContext(context.Background(), count)
+}
+
+func (cli *Client) LikeProposalsContext(ctx context.Context, count int) (*LikeReport, error) {
+	return cli.Like(ctx, KindProposals, count)
+}
+
+func (cli *Client) LikeProposalsPaged(ctx context.Context, opts ListOptions) (*LikeReport, error) {
+	return cli.LikePaged(ctx, KindProposals, opts)
+}
+
+// latestPosts returns the options for fetching count of the latest posts in
+// a single request
+func latestPosts(count int) ListOptions {
+	return ListOptions{PageSize: count, MaxPosts: count}
+}
+
+// Like visits count of the latest posts of the kind and returns the report of
+// the visited posts. Use LikePaged for visiting all posts.
+func (cli *Client) Like(ctx context.Context, kind PostKind, count int) (*LikeReport, error) {
+	if count <= 0 {
+		return nil, ErrInvalidCount
 	}
-	return cli.likePosts(posts, ccpNoticeConfig)
+	return cli.LikePaged(ctx, kind, latestPosts(count))
 }
 
-func (cli *Client) LikeProposals(count int) int {
+// LikePaged is like Like but fetches the posts page by page as controlled by
+// opts
+func (cli *Client) LikePaged(ctx context.Context, kind PostKind, opts ListOptions) (*LikeReport, error) {
 	if err := cli.ensureLoggedIn(); err != nil {
-		return 0
+		return nil, err
 	}
+	defer cli.holdCommunity(ctx)()
 
-	posts, err := cli.getPosts(
-		proposalConfig.listPostApiPath,
-		proposalConfig.listPostParams,
-		count)
+	start := time.Now()
+	posts, err := cli.listPosts(ctx, kind, opts)
 	if err != nil {
-		log.Print(err)
-		return 0
+		return nil, err
 	}
-	return cli.likePosts(posts, proposalConfig)
+	report := &LikeReport{Posts: cli.likePosts(ctx, posts, kind)}
+	report.Duration = time.Since(start)
+	return report, nil
 }
 
-func (cli *Client) likePosts(posts []post, config likePostConfig) int {
-	communityId := cli.CurrentCommunity().MemberId
-	newPosts := lo.Filter(posts, func(p post, i int) bool {
-		res, err := cli.history.Has(LikedPost{communityId, p.likeId})
+func (cli *Client) likePosts(ctx context.Context, posts []Post, kind PostKind) []PostResult {
+	community := cli.CurrentCommunity()
+	communityId := community.MemberId
+	filter := cli.filter.Load()
+	now := time.Now()
+	results := make([]PostResult, len(posts))
+	wg := sync.WaitGroup{}
+	for i, p := range posts {
+		res := &results[i]
+		res.ViewId = p.ViewId
+		res.LikeId = p.LikeId
+		if err := ctx.Err(); err != nil {
+			res.Outcome = OutcomeFailed
+			res.Err = err
+			continue
+		}
+		// stop sending requests once a like finds the session expired
+		if err := cli.ensureLoggedIn(); err != nil {
+			res.Outcome = OutcomeFailed
+			res.Err = err
+			continue
+		}
+		if filter != nil {
+			allowed, err := filter.Allow(kind, community, p, now)
+			if err != nil {
+				res.Outcome = OutcomeFailed
+				res.Err = err
+				continue
+			}
+			if !allowed {
+				res.Outcome = OutcomeFiltered
+				continue
+			}
+		}
+		liked, err := cli.history.Has(LikedPost{MemberId: communityId, PostId: p.LikeId})
 		if err != nil {
-			log.Printf("failed to check liked post: %v", err)
-			return false
+			res.Outcome = OutcomeFailed
+			res.Err = fmt.Errorf("failed to check liked post: %w", err)
+			continue
+		}
+		if liked {
+			res.Outcome = OutcomeSkipped
+			continue
+		}
+
+		if err := cli.acquire(ctx); err != nil {
+			res.Outcome = OutcomeFailed
+			res.Err = err
+			continue
 		}
-		return !res
-	})
-	wg := sync.WaitGroup{}
-	n := atomic.Int32{}
-	for _, p := range newPosts {
 		wg.Add(1)
-		go func(p post) {
+		go func(p Post) {
 			defer wg.Done()
-			liked, err := cli.likePost(config.viewPostApiPath, config.favText, p)
+			defer cli.release()
+			outcome, retries, err := cli.likePost(ctx, kind.ViewApiPath, kind.LikeText, p)
+			res.Retries = retries
+			res.Outcome = outcome
 			if err != nil {
-				log.Print(err)
-			} else {
-				if err := cli.history.Add(LikedPost{communityId, p.likeId}); err != nil {
-					log.Printf("failed to add liked post: %v", err)
-					return
-				}
-				if liked {
-					n.Add(1)
-				}
+				res.Outcome = OutcomeFailed
+				res.Err = err
+				return
+			}
+			if outcome == OutcomeClosed || cli.dryRun {
+				return
+			}
+			if err := cli.history.Add(LikedPost{
+				MemberId: communityId,
+				PostId:   p.LikeId,
+				LikedAt:  time.Now(),
+				ByClient: outcome == OutcomeLiked,
+			}); err != nil {
+				log.Printf("failed to add liked post: %v", err)
 			}
 		}(p)
 	}
 	wg.Wait()
-	return int(n.Load())
+	return results
 }
 
-// getPosts returns count of posts of the latest notices
-func (cli *Client) getPosts(apiPath string, queryParams map[string]string, count int) ([]post, error) {
-	resp, err := get(
-		cli.httpclient.R().
-			SetQueryParams(queryParams).
-			SetQueryParam("begin", "0").
-			SetQueryParam("count", strconv.Itoa(count)),
-		apiPath)
+// listPosts fetches the list of posts page by page until any of the stop
+// conditions in opts is met
+func (cli *Client) listPosts(ctx context.Context, kind PostKind, opts ListOptions) ([]Post, error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = kDefaultPageSize
+	}
+	if opts.MaxPosts > 0 && opts.MaxPosts < pageSize {
+		pageSize = opts.MaxPosts
+	}
+	communityId := cli.CurrentCommunity().MemberId
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(resp.String()))
+	var posts []Post
+	seen := make(map[string]bool)
+	var numKnown int
+	for begin := 0; ; begin += pageSize {
+		page, err := cli.getPosts(ctx, kind.ListApiPath, kind.ListParams, begin, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.Since.IsZero() && len(page) != 0 && lo.EveryBy(page, func(p Post) bool {
+			return p.PublishedAt.IsZero()
+		}) {
+			return nil, &ParseError{Selector: ".time", Err: errors.New("no post shows its publish time to apply since")}
+		}
+		var numNew int
+		for _, p := range page {
+			if !opts.Since.IsZero() && !p.PublishedAt.IsZero() && p.PublishedAt.Before(opts.Since) {
+				return posts, nil
+			}
+			// the list may shift while paging, don't visit a post twice
+			if seen[p.LikeId] {
+				continue
+			}
+			seen[p.LikeId] = true
+			numNew++
+			posts = append(posts, p)
+			if opts.MaxPosts > 0 && len(posts) == opts.MaxPosts {
+				return posts, nil
+			}
+
+			if opts.StopAfterKnown > 0 {
+				known, err := cli.history.Has(LikedPost{MemberId: communityId, PostId: p.LikeId})
+				if err != nil {
+					log.Printf("failed to check liked post: %v", err)
+				}
+				if known {
+					numKnown++
+				} else {
+					numKnown = 0
+				}
+				if numKnown >= opts.StopAfterKnown {
+					return posts, nil
+				}
+			}
+		}
+		// stop at the last page, or if the server keeps returning the same page
+		if len(page) < pageSize || numNew == 0 {
+			return posts, nil
+		}
+	}
+}
+
+// acquire waits for a free slot for liking a post
+func (cli *Client) acquire(ctx context.Context) error {
+	if cli.inFlight == nil {
+		return nil
+	}
+	select {
+	case cli.inFlight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees the slot taken by acquire
+func (cli *Client) release() {
+	if cli.inFlight != nil {
+		<-cli.inFlight
+	}
+}
+
+// throttle waits until the rate limiter allows the next request
+func (cli *Client) throttle(ctx context.Context) error {
+	if cli.limiter == nil {
+		return nil
+	}
+	return cli.limiter.Wait(ctx)
+}
+
+// getPosts returns count of posts starting at begin
+func (cli *Client) getPosts(ctx context.Context, apiPath string, queryParams map[string]string, begin int, count int) ([]Post, error) {
+	var resp *resty.Response
+	_, err := cli.retry(ctx, apiPath, func() error {
+		if err := cli.throttle(ctx); err != nil {
+			return err
+		}
+		var err error
+		resp, err = get(
+			cli.r(ctx, OpList).
+				SetQueryParams(queryParams).
+				SetQueryParam("begin", strconv.Itoa(begin)).
+				SetQueryParam("count", strconv.Itoa(count)),
+			apiPath)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var posts []post
-	doc.Find("body > div").Each(func(i int, e *goquery.Selection) {
-		idValue, _ := e.Attr("id")
-		hrefValue, _ := e.Find("a").First().Attr("href")
-		posts = append(posts, post{
-			viewId: hrefValue[strings.IndexRune(hrefValue, '=')+1 : strings.LastIndex(hrefValue, "'")],
-			likeId: idValue[2:],
-		})
+	items, err := parse.List(bytes.NewReader(resp.Body()))
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(items, func(e parse.ListItem, i int) Post {
+		return Post(e)
+	}), nil
+}
+
+// getPostPage returns the page of the post and the number of retried requests
+func (cli *Client) getPostPage(ctx context.Context, apiPath string, viewId string) (*parse.PostPage, int, error) {
+	var page *parse.PostPage
+	retries, err := cli.retry(ctx, apiPath, func() error {
+		var err error
+		page, err = cli.viewPost(ctx, apiPath, viewId)
+		return err
 	})
-	return posts, nil
+	if err != nil {
+		return nil, retries, err
+	}
+	return page, retries, nil
 }
 
-func (cli *Client) likePost(apiPath string, favText string, p post) (bool, error) {
-	resp, err := getWithJsonError(cli.httpclient.R(), apiPath+p.viewId)
+// viewPost requests the page of the post once
+func (cli *Client) viewPost(ctx context.Context, apiPath string, viewId string) (*parse.PostPage, error) {
+	pageUrl, err := url.Parse(cli.baseUrl + apiPath + viewId)
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.throttle(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := getWithJsonError(cli.r(ctx, OpView), apiPath+viewId)
 	if err != nil {
-		return false, fmt.Errorf("get post error: %v, %s", err, p.viewId)
+		return nil, fmt.Errorf("get post error: %w, %s", err, viewId)
+	}
+	return parse.View(bytes.NewReader(resp.Body()), pageUrl)
+}
+
+// likePost likes the post if it has not been liked. It returns the outcome of
+// the post, and the number of retried requests.
+func (cli *Client) likePost(ctx context.Context, apiPath string, favText string, p Post) (LikeOutcome, int, error) {
+	page, retries, err := cli.getPostPage(ctx, apiPath, p.ViewId)
+	if err != nil {
+		return OutcomeFailed, retries, err
 	}
 
+	// never like a proposal which is no longer open
+	if page.Proposal != nil {
+		status, err := proposalStatus(page.Proposal.Status)
+		if err != nil {
+			return OutcomeFailed, retries, err
+		}
+		if status != ProposalOpen {
+			return OutcomeClosed, retries, nil
+		}
+	}
 	// only like when the post has not been liked
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(resp.String()))
-	if doc.Find("span#cmdLike").First().Text() != favText {
-		return false, nil
+	if page.LikeText == "" {
+		return OutcomeFailed, retries, &ParseError{Selector: "span#cmdLike"}
+	}
+	if page.LikeText != favText {
+		return OutcomeAlreadyLiked, retries, nil
+	}
+	if cli.dryRun {
+		return OutcomeWouldLike, retries, nil
 	}
 
-	_, err = getWithJsonError(cli.httpclient.R().SetQueryParam("title", p.likeId), "/community/title_like")
+	var attempts int
+	n, err := cli.retry(ctx, "/community/title_like", func() error {
+		// a like which failed with a timeout may have reached the server,
+		// check the post before liking it again
+		if attempts++; attempts > 1 {
+			page, err := cli.viewPost(ctx, apiPath, p.ViewId)
+			if err != nil {
+				return err
+			}
+			if page.LikeText == "" {
+				return &ParseError{Selector: "span#cmdLike"}
+			}
+			if page.LikeText != favText {
+				return nil
+			}
+		}
+		if err := cli.throttle(ctx); err != nil {
+			return err
+		}
+		_, err := getWithJsonError(cli.r(ctx, OpLike).SetQueryParam("title", p.LikeId), "/community/title_like")
+		return err
+	})
+	retries += n
 	if err != nil {
-		return false, fmt.Errorf("like error: %v, %s", err, p.likeId)
+		return OutcomeFailed, retries, fmt.Errorf("like error: %w, %s", err, p.LikeId)
 	}
-	return true, nil
+	return OutcomeLiked, retries, nil
 }
 
 func (cli *Client) ensureLoggedIn() error {
@@ -0,0 +1,389 @@
+package atom_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+var published = time.Date(2023, 6, 1, 9, 30, 0, 0, time.UTC)
+
+// newServer starts a fake server with two communities, each of which has an
+// unliked and a liked notice
+func newServer(t *testing.T) *atomtest.Server {
+	t.Helper()
+	s := atomtest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddCommunity(atomtest.Community{Name: "东区", MemberId: "m1"})
+	s.AddCommunity(atomtest.Community{Name: "西区", MemberId: "m2"})
+	for _, member := range []string{"m1", "m2"} {
+		s.AddPost(member, atomtest.KindNotices, atomtest.Post{
+			ViewId: member + "-v1",
+			LikeId: member + "-1",
+			Title:  "停水通知",
+			Author: "物业服务中心",
+			Time:   published,
+		})
+		s.AddPost(member, atomtest.KindNotices, atomtest.Post{
+			ViewId: member + "-v2",
+			LikeId: member + "-2",
+			Title:  "端午节活动报名",
+			Author: "居委会",
+			Time:   published.Add(-time.Hour),
+			Likes:  1,
+			Liked:  true,
+		})
+	}
+	return s
+}
+
+// addNotices publishes n notices in the community of member, published an
+// hour apart from published backwards. The like id of the ith is member-n-i.
+func addNotices(s *atomtest.Server, member string, n int) {
+	for i := 0; i < n; i++ {
+		s.AddPost(member, atomtest.KindNotices, atomtest.Post{
+			ViewId: fmt.Sprintf("%s-n-v%d", member, i),
+			LikeId: fmt.Sprintf("%s-n-%d", member, i),
+			Title:  fmt.Sprintf("通知%d", i),
+			Time:   published.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+}
+
+// memHistory is a LikedPostsHistory kept in memory
+type memHistory struct {
+	mtx   sync.Mutex
+	posts []atom.LikedPost
+}
+
+func (h *memHistory) Has(post atom.LikedPost) (bool, error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for _, p := range h.posts {
+		if p.MemberId == post.MemberId && p.PostId == post.PostId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *memHistory) Add(post atom.LikedPost) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.posts = append(h.posts, post)
+	return nil
+}
+
+func newClient(s *atomtest.Server, opts ...atom.Option) *atom.Client {
+	opts = append([]atom.Option{
+		atom.WithBaseURL(s.URL()),
+		atom.WithRetryPolicy(atom.NoRetry),
+	}, opts...)
+	return atom.NewClient(atom.NullLikedPostsHistory{}, opts...)
+}
+
+// startLogin starts a qr login of the client, whose events are sent to the
+// returned channel
+func startLogin(t *testing.T, cli *atom.Client) (string, <-chan atom.LoginEvent) {
+	t.Helper()
+	events := make(chan atom.LoginEvent, 8)
+	qrUrl, err := cli.StartQRLogin(func(e atom.LoginEvent) { events <- e })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qrUrl, events
+}
+
+// waitLogin returns the first event ending the login
+func waitLogin(t *testing.T, events <-chan atom.LoginEvent) atom.LoginEvent {
+	t.Helper()
+	for {
+		select {
+		case e := <-events:
+			if e.Kind != atom.LoginQRScanned {
+				return e
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("login timed out")
+		}
+	}
+}
+
+// login logs in the client by scanning its qr code. If s is nil, the scan is
+// expected to be replayed.
+func login(t *testing.T, s *atomtest.Server, cli *atom.Client) {
+	t.Helper()
+	qrUrl, events := startLogin(t, cli)
+	if s != nil {
+		if err := s.ScanQRCode(qrUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e := waitLogin(t, events); e.Kind != atom.LoggedIn {
+		t.Fatalf("login: %v %v", e.Kind, e.Err)
+	}
+}
+
+func TestLoginRejected(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	qrUrl, events := startLogin(t, cli)
+	if err := s.RejectQRCode(qrUrl); err != nil {
+		t.Fatal(err)
+	}
+	e := waitLogin(t, events)
+	var statusErr *atom.UpstreamStatusError
+	if e.Kind != atom.LoginFailed || !errors.As(e.Err, &statusErr) || statusErr.Code != http.StatusForbidden {
+		t.Fatalf("got %v %v, want LoginFailed with 403", e.Kind, e.Err)
+	}
+	if cli.IsLoggedIn() {
+		t.Error("client is logged in after a rejected login")
+	}
+	// a new login can start at once
+	login(t, s, cli)
+}
+
+func TestLoginQRExpired(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	qrUrl, events := startLogin(t, cli)
+	if err := s.ExpireQRCode(qrUrl); err != nil {
+		t.Fatal(err)
+	}
+	if e := waitLogin(t, events); e.Kind != atom.LoginQRExpired {
+		t.Fatalf("got %v %v, want LoginQRExpired", e.Kind, e.Err)
+	}
+	if cli.IsLoggedIn() {
+		t.Error("client is logged in with an expired qr code")
+	}
+	login(t, s, cli)
+}
+
+func TestLike(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	login(t, s, cli)
+	if got := cli.CurrentCommunity().MemberId; got != "m1" {
+		t.Fatalf("current community %s, want m1", got)
+	}
+
+	report, err := cli.Like(context.Background(), atom.KindNotices, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is not liked")
+	}
+	if s.IsLiked("m2", atomtest.KindNotices, "m2-1") {
+		t.Error("m2-1 of another community is liked")
+	}
+	if n := report.Count(atom.OutcomeLiked); n != 1 {
+		t.Errorf("got %d liked, want 1", n)
+	}
+	if n := report.Count(atom.OutcomeAlreadyLiked); n != 1 {
+		t.Errorf("got %d already liked, want 1", n)
+	}
+}
+
+func TestLikeRetry(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s, atom.WithRetryPolicy(atom.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}))
+	login(t, s, cli)
+
+	s.FailNext("/community/title_like", 2, 503)
+	report, err := cli.Like(context.Background(), atom.KindNotices, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Posts) != 1 || report.Posts[0].Outcome != atom.OutcomeLiked {
+		t.Fatalf("unexpected report: %v", report)
+	}
+	if !s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is not liked")
+	}
+}
+
+func TestLikePaged(t *testing.T) {
+	s := atomtest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddCommunity(atomtest.Community{Name: "东区", MemberId: "m1"})
+	addNotices(s, "m1", 25)
+
+	history := &memHistory{}
+	for i := 5; i < 10; i++ {
+		history.Add(atom.LikedPost{MemberId: "m1", PostId: fmt.Sprintf("m1-n-%d", i)})
+	}
+	cli := atom.NewClient(history, atom.WithBaseURL(s.URL()), atom.WithRetryPolicy(atom.NoRetry))
+	login(t, s, cli)
+
+	for _, tc := range []struct {
+		name string
+		opts atom.ListOptions
+		want int
+	}{
+		// stops at 7, the third of the known posts, before the others are liked
+		{"stop after known", atom.ListOptions{PageSize: 4, StopAfterKnown: 3}, 8},
+		{"max posts", atom.ListOptions{PageSize: 10, MaxPosts: 23}, 23},
+		{"all", atom.ListOptions{PageSize: 10}, 25},
+		// the posts of 0 to 14 hours ago
+		{"since", atom.ListOptions{PageSize: 10, Since: published.Add(-14*time.Hour - time.Minute)}, 15},
+	} {
+		report, err := cli.LikePaged(context.Background(), atom.KindNotices, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(report.Posts) != tc.want {
+			t.Errorf("%s: got %d posts, want %d", tc.name, len(report.Posts), tc.want)
+		}
+	}
+}
+
+func TestLikePagedUnknownTime(t *testing.T) {
+	s := newServer(t)
+	s.AddPost("m1", atomtest.KindNotices, atomtest.Post{ViewId: "v", LikeId: "unknown-time"})
+	cli := newClient(s)
+	login(t, s, cli)
+
+	// the post of unknown time is kept
+	report, err := cli.LikePaged(context.Background(), atom.KindNotices, atom.ListOptions{
+		Since: published.Add(-2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Posts) != 3 {
+		t.Errorf("got %d posts, want 3", len(report.Posts))
+	}
+
+	s = newServer(t)
+	s.AddCommunity(atomtest.Community{Name: "北区", MemberId: "m3"})
+	s.AddPost("m3", atomtest.KindNotices, atomtest.Post{ViewId: "v", LikeId: "unknown-time"})
+	cli = newClient(s)
+	login(t, s, cli)
+	if err := cli.SetCurrentCommunityById("m3"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = cli.LikePaged(context.Background(), atom.KindNotices, atom.ListOptions{Since: published})
+	if !errors.Is(err, atom.ErrParse) {
+		t.Fatalf("got %v, want ErrParse", err)
+	}
+}
+
+func TestLikeInvalidCount(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	login(t, s, cli)
+	if _, err := cli.Like(context.Background(), atom.KindNotices, 0); !errors.Is(err, atom.ErrInvalidCount) {
+		t.Fatalf("got %v, want ErrInvalidCount", err)
+	}
+}
+
+func TestLikeDryRun(t *testing.T) {
+	s := newServer(t)
+	history := &memHistory{}
+	cli := atom.NewClient(history, atom.WithBaseURL(s.URL()), atom.WithDryRun())
+	login(t, s, cli)
+
+	report, err := cli.Like(context.Background(), atom.KindNotices, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := report.Count(atom.OutcomeWouldLike); n != 1 {
+		t.Errorf("got %d would like, want 1", n)
+	}
+	if n := report.Count(atom.OutcomeAlreadyLiked); n != 1 {
+		t.Errorf("got %d already liked, want 1", n)
+	}
+	if s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is liked in a dry run")
+	}
+	if len(history.posts) != 0 {
+		t.Errorf("got %d posts added to the history in a dry run", len(history.posts))
+	}
+}
+
+func TestWithCommunity(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	login(t, s, cli)
+
+	switched := make(chan error, 1)
+	err := cli.WithCommunity(context.Background(), "m2", func(ctx context.Context) error {
+		if err := cli.SetCurrentCommunityByIdContext(ctx, "m1"); !errors.Is(err, atom.ErrCommunityHeld) {
+			t.Errorf("switch in f: got %v, want ErrCommunityHeld", err)
+		}
+		// a concurrent switch waits until f returns
+		go func() { switched <- cli.SetCurrentCommunityById("m1") }()
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case err := <-switched:
+			t.Fatalf("community switched while held: %v", err)
+		default:
+		}
+		_, err := cli.Like(ctx, atom.KindNotices, 10)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-switched; err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsLiked("m2", atomtest.KindNotices, "m2-1") {
+		t.Error("m2-1 is not liked")
+	}
+	if s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is liked by the switch while the community is held")
+	}
+	if got := cli.CurrentCommunity().MemberId; got != "m1" {
+		t.Errorf("current community %s after the switch, want m1", got)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	s := newServer(t)
+	var buf bytes.Buffer
+	rec := atom.NewRecorder(&buf)
+	cli := newClient(s, atom.WithRecorder(rec))
+	login(t, s, cli)
+	recorded, err := cli.Like(context.Background(), atom.KindNotices, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	cassette, err := atom.LoadCassette(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.WebSockets) != 1 {
+		t.Fatalf("got %d websockets, want 1", len(cassette.WebSockets))
+	}
+	// nothing reaches the upstream while replaying
+	s.Close()
+
+	replayed := newClient(s, atom.WithReplayer(atom.NewReplayer(cassette)))
+	login(t, nil, replayed)
+	report, err := replayed.Like(context.Background(), atom.KindNotices, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []atom.LikeOutcome{atom.OutcomeLiked, atom.OutcomeAlreadyLiked} {
+		if got, want := report.Count(o), recorded.Count(o); got != want {
+			t.Errorf("got %d %v, want %d", got, o, want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package atom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexshen/juweitong/atom/parse"
+	"github.com/samber/lo"
+)
+
+// The comment endpoint and the comment markup are not confirmed against
+// juweitong yet, see atom/parse/testdata/README.md
+const kCommentApiPath = "/community/comment_add"
+
+var ErrEmptyComment = errors.New("empty comment")
+
+// Comment is a comment on a post
+type Comment struct {
+	Id          string
+	Author      string
+	Text        string
+	PublishedAt time.Time // zero if unknown
+	ReplyTo     string    // id of the replied comment, empty if replying the post
+}
+
+// ListComments returns the comments on the post with the view id in the
+// current community, the earliest first
+func (cli *Client) ListComments(ctx context.Context, kind PostKind, viewId string) ([]Comment, error) {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+	defer cli.holdCommunity(ctx)()
+	page, _, err := cli.getPostPage(ctx, kind.ViewApiPath, viewId)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(page.Comments, func(e parse.Comment, i int) Comment {
+		return Comment(e)
+	}), nil
+}
+
+// PostComment comments on the post with the like id as the current member.
+// If replyTo is not empty, the comment replies the comment with the id.
+func (cli *Client) PostComment(ctx context.Context, likeId string, text string, replyTo string) error {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return err
+	}
+	defer cli.holdCommunity(ctx)()
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ErrEmptyComment
+	}
+	if err := cli.throttle(ctx); err != nil {
+		return err
+	}
+	// not retried as a comment which has reached the server would be posted twice
+	params := map[string]string{
+		"title":   likeId,
+		"content": text,
+	}
+	if replyTo != "" {
+		params["replyTo"] = replyTo
+	}
+	_, err := getWithJsonError(cli.r(ctx, OpComment).SetQueryParams(params), kCommentApiPath)
+	if err != nil {
+		return fmt.Errorf("comment error: %w, %s", err, likeId)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package atom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexshen/juweitong/atom"
+)
+
+func TestComments(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	login(t, s, cli)
+	ctx := context.Background()
+
+	if err := cli.PostComment(ctx, "m1-1", " ", ""); !errors.Is(err, atom.ErrEmptyComment) {
+		t.Fatalf("got %v, want ErrEmptyComment", err)
+	}
+	if err := cli.PostComment(ctx, "m1-1", "收到", ""); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := cli.ListComments(ctx, atom.KindNotices, "m1-v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Text != "收到" || comments[0].Id == "" || comments[0].ReplyTo != "" {
+		t.Fatalf("unexpected comments: %+v", comments)
+	}
+
+	if err := cli.PostComment(ctx, "m1-1", "谢谢", comments[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if comments, err = cli.ListComments(ctx, atom.KindNotices, "m1-v1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[1].Text != "谢谢" || comments[1].ReplyTo != comments[0].Id {
+		t.Fatalf("unexpected comments: %+v", comments)
+	}
+	if n, _ := cli.ListComments(ctx, atom.KindNotices, "m1-v2"); len(n) != 0 {
+		t.Errorf("got %d comments on another post", len(n))
+	}
+}
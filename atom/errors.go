@@ -0,0 +1,39 @@
+package atom
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/alexshen/juweitong/atom/parse"
+)
+
+var (
+	// ErrUpstreamStatus is matched by errors of non-2xx upstream responses,
+	// use errors.As with *UpstreamStatusError for the details
+	ErrUpstreamStatus = errors.New("unexpected upstream status")
+	// ErrRateLimited is matched by errors of 429 upstream responses
+	ErrRateLimited = errors.New("rate limited by upstream")
+	// ErrParse is matched by errors of unexpected upstream pages, use
+	// errors.As with *ParseError for the details
+	ErrParse = parse.ErrMalformed
+)
+
+// UpstreamStatusError is returned for non-2xx upstream responses
+type UpstreamStatusError struct {
+	Path   string // path of the request without the query
+	Code   int
+	Status string
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Status)
+}
+
+func (e *UpstreamStatusError) Is(target error) bool {
+	return target == ErrUpstreamStatus ||
+		target == ErrRateLimited && e.Code == http.StatusTooManyRequests
+}
+
+// ParseError is returned if an upstream page cannot be parsed
+type ParseError = parse.Error
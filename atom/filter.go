@@ -0,0 +1,161 @@
+package atom
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// RuleAction is what to do with the posts matched by a rule
+type RuleAction string
+
+const (
+	ActionLike RuleAction = "like"
+	ActionSkip RuleAction = "skip"
+)
+
+// Rule matches a post if all of its non-empty conditions hold
+type Rule struct {
+	Action      RuleAction `json:"action"`
+	Kinds       []string   `json:"kinds,omitempty"`       // names of the post kinds
+	Communities []string   `json:"communities,omitempty"` // names or member ids of the communities
+	Keywords    []string   `json:"keywords,omitempty"`    // any of them appears in the title
+	Authors     []string   `json:"authors,omitempty"`
+	MinAge      string     `json:"min_age,omitempty"` // e.g. 24h
+	MaxAge      string     `json:"max_age,omitempty"`
+}
+
+// FilterRules is the declarative form of a Filter. The rules are matched in
+// order, and the action of the first matched rule is taken. If no rule
+// matches, the default action is taken, which is ActionLike if empty.
+type FilterRules struct {
+	Default RuleAction `json:"default,omitempty"`
+	Rules   []Rule     `json:"rules"`
+}
+
+type compiledRule struct {
+	Rule
+	minAge time.Duration
+	maxAge time.Duration
+}
+
+// Filter decides which posts to like
+type Filter struct {
+	rules []compiledRule
+	def   RuleAction
+}
+
+// NewFilter compiles the rules into a filter
+func NewFilter(r FilterRules) (*Filter, error) {
+	f := &Filter{def: r.Default}
+	if f.def == "" {
+		f.def = ActionLike
+	}
+	if err := validateAction(f.def); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	for i, rule := range r.Rules {
+		c := compiledRule{Rule: rule}
+		if err := validateAction(rule.Action); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		for _, k := range rule.Kinds {
+			if _, ok := LookupPostKind(k); !ok {
+				return nil, fmt.Errorf("rule %d: unknown post kind %q", i, k)
+			}
+		}
+		var err error
+		if c.minAge, err = parseAge(rule.MinAge); err != nil {
+			return nil, fmt.Errorf("rule %d: min_age: %w", i, err)
+		}
+		if c.maxAge, err = parseAge(rule.MaxAge); err != nil {
+			return nil, fmt.Errorf("rule %d: max_age: %w", i, err)
+		}
+		f.rules = append(f.rules, c)
+	}
+	return f, nil
+}
+
+// Allow returns true if the post of the kind in the community should be liked.
+// The title, the author and the time of a post are not sure to be shown, so a
+// ParseError is returned if a rule checked before a match needs one of them
+// which the post lacks, rather than taking the rule as not matching.
+func (f *Filter) Allow(kind PostKind, community Community, p Post, now time.Time) (bool, error) {
+	for _, r := range f.rules {
+		matched, err := r.match(kind, community, p, now)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return r.Action == ActionLike, nil
+		}
+	}
+	return f.def == ActionLike, nil
+}
+
+func (r *compiledRule) match(kind PostKind, community Community, p Post, now time.Time) (bool, error) {
+	if len(r.Kinds) != 0 && !lo.Contains(r.Kinds, kind.Name) {
+		return false, nil
+	}
+	if len(r.Communities) != 0 &&
+		!lo.Contains(r.Communities, community.Name) && !lo.Contains(r.Communities, community.MemberId) {
+		return false, nil
+	}
+	if len(r.Authors) != 0 {
+		if p.Author == "" {
+			return false, missingField(".author", p)
+		}
+		if !lo.Contains(r.Authors, p.Author) {
+			return false, nil
+		}
+	}
+	if len(r.Keywords) != 0 {
+		if p.Title == "" {
+			return false, missingField(".title", p)
+		}
+		if !lo.ContainsBy(r.Keywords, func(k string) bool {
+			return strings.Contains(p.Title, k)
+		}) {
+			return false, nil
+		}
+	}
+	if r.minAge != 0 || r.maxAge != 0 {
+		if p.PublishedAt.IsZero() {
+			return false, missingField(".time", p)
+		}
+		age := now.Sub(p.PublishedAt)
+		if r.minAge != 0 && age < r.minAge {
+			return false, nil
+		}
+		if r.maxAge != 0 && age > r.maxAge {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// missingField returns the error of a rule needing the field of the selector
+// which is not found for the post
+func missingField(selector string, p Post) error {
+	return &ParseError{Selector: selector, Err: fmt.Errorf("not found for filtering post %s", p.LikeId)}
+}
+
+func validateAction(a RuleAction) error {
+	if a != ActionLike && a != ActionSkip {
+		return fmt.Errorf("invalid action %q", a)
+	}
+	return nil
+}
+
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("negative age %s", s)
+	}
+	return d, err
+}
@@ -0,0 +1,152 @@
+package atom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+func TestNewFilterInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules atom.FilterRules
+	}{
+		{"default", atom.FilterRules{Default: "unlike"}},
+		{"action", atom.FilterRules{Rules: []atom.Rule{{Action: ""}}}},
+		{"kind", atom.FilterRules{Rules: []atom.Rule{{Action: atom.ActionSkip, Kinds: []string{"polls"}}}}},
+		{"min_age", atom.FilterRules{Rules: []atom.Rule{{Action: atom.ActionSkip, MinAge: "1 day"}}}},
+		{"max_age", atom.FilterRules{Rules: []atom.Rule{{Action: atom.ActionSkip, MaxAge: "-1h"}}}},
+	} {
+		if _, err := atom.NewFilter(tc.rules); err == nil {
+			t.Errorf("%s: invalid rules accepted", tc.name)
+		}
+	}
+}
+
+func TestFilterAllow(t *testing.T) {
+	now := time.Date(2023, 6, 2, 12, 0, 0, 0, time.UTC)
+	f, err := atom.NewFilter(atom.FilterRules{
+		Default: atom.ActionSkip,
+		Rules: []atom.Rule{
+			{Action: atom.ActionSkip, Keywords: []string{"广告"}},
+			{Action: atom.ActionLike, Kinds: []string{atom.KindNotices.Name}, Communities: []string{"东区"}},
+			{Action: atom.ActionLike, Authors: []string{"居委会"}, MaxAge: "24h"},
+			{Action: atom.ActionLike, Communities: []string{"m2"}, MinAge: "48h"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	east := atom.Community{Name: "东区", MemberId: "m1"}
+	west := atom.Community{Name: "西区", MemberId: "m2"}
+	for _, tc := range []struct {
+		name      string
+		kind      atom.PostKind
+		community atom.Community
+		post      atom.Post
+		want      bool
+	}{
+		{"first match wins", atom.KindNotices, east, atom.Post{Title: "广告"}, false},
+		{"kind and community", atom.KindNotices, east, atom.Post{Title: "通知"}, true},
+		{"other kind", atom.KindMoments, east, atom.Post{Title: "通知", Author: "物业服务中心"}, false},
+		{"author and max age", atom.KindMoments, west,
+			atom.Post{Title: "通知", Author: "居委会", PublishedAt: now.Add(-time.Hour)}, true},
+		{"too old", atom.KindMoments, east,
+			atom.Post{Title: "通知", Author: "居委会", PublishedAt: now.Add(-25 * time.Hour)}, false},
+		{"member id and min age", atom.KindMoments, west,
+			atom.Post{Title: "通知", Author: "物业服务中心", PublishedAt: now.Add(-72 * time.Hour)}, true},
+		{"too new", atom.KindMoments, west,
+			atom.Post{Title: "通知", Author: "物业服务中心", PublishedAt: now.Add(-time.Hour)}, false},
+	} {
+		got, err := f.Allow(tc.kind, tc.community, tc.post, now)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFilterMissingField(t *testing.T) {
+	now := time.Date(2023, 6, 2, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		rule     atom.Rule
+		selector string
+	}{
+		{"keywords", atom.Rule{Action: atom.ActionSkip, Keywords: []string{"广告"}}, ".title"},
+		{"authors", atom.Rule{Action: atom.ActionSkip, Authors: []string{"居委会"}}, ".author"},
+		{"min age", atom.Rule{Action: atom.ActionSkip, MinAge: "1h"}, ".time"},
+		{"max age", atom.Rule{Action: atom.ActionSkip, MaxAge: "1h"}, ".time"},
+	} {
+		f, err := atom.NewFilter(atom.FilterRules{Rules: []atom.Rule{tc.rule}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Allow(atom.KindNotices, atom.Community{}, atom.Post{LikeId: "1"}, now)
+		var parseErr *atom.ParseError
+		if !errors.As(err, &parseErr) || parseErr.Selector != tc.selector {
+			t.Errorf("%s: got %v, want a parse error at %s", tc.name, err, tc.selector)
+		}
+	}
+
+	// a rule not applying to the kind needs no field
+	f, err := atom.NewFilter(atom.FilterRules{Rules: []atom.Rule{
+		{Action: atom.ActionSkip, Kinds: []string{atom.KindMoments.Name}, MaxAge: "1h"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := f.Allow(atom.KindNotices, atom.Community{}, atom.Post{}, now); !ok || err != nil {
+		t.Errorf("got %v %v, want the default action", ok, err)
+	}
+}
+
+func TestLikeFiltered(t *testing.T) {
+	s := newServer(t)
+	s.AddPost("m1", atomtest.KindNotices, atomtest.Post{ViewId: "v", LikeId: "unknown-time", Author: "居委会"})
+	f, err := atom.NewFilter(atom.FilterRules{Rules: []atom.Rule{
+		{Action: atom.ActionSkip, Authors: []string{"物业服务中心"}},
+		{Action: atom.ActionLike, MaxAge: "24h"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := newClient(s, atom.WithFilter(f))
+	login(t, s, cli)
+
+	report, err := cli.Like(context.Background(), atom.KindNotices, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range report.Posts {
+		switch p.LikeId {
+		case "m1-1":
+			if p.Outcome != atom.OutcomeFiltered {
+				t.Errorf("m1-1: got %v, want filtered", p.Outcome)
+			}
+		case "unknown-time":
+			if p.Outcome != atom.OutcomeFailed || !errors.Is(p.Err, atom.ErrParse) {
+				t.Errorf("unknown-time: got %v %v, want failed with ErrParse", p.Outcome, p.Err)
+			}
+		}
+	}
+	if s.IsLiked("m1", atomtest.KindNotices, "m1-1") || s.IsLiked("m1", atomtest.KindNotices, "unknown-time") {
+		t.Error("a post not allowed by the filter is liked")
+	}
+}
+
+func TestFilterDefault(t *testing.T) {
+	f, err := atom.NewFilter(atom.FilterRules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := f.Allow(atom.KindNotices, atom.Community{}, atom.Post{}, time.Now()); !ok || err != nil {
+		t.Error("posts are not liked by default")
+	}
+}
@@ -0,0 +1,110 @@
+package atom
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// PostKind describes a section of posts that can be liked
+type PostKind struct {
+	Name        string            // unique name of the kind, e.g. notices
+	Title       string            // display name of the kind
+	ListApiPath string            // path of the api listing the posts
+	ListParams  map[string]string // query params for listing the posts
+	ViewApiPath string            // path of the post page, the view id is appended
+	LikeText    string            // text of the like button of a post not liked yet
+}
+
+var (
+	KindNotices = PostKind{
+		Name:        "notices",
+		Title:       "公告",
+		ListApiPath: "/community/notice_list_more",
+		ListParams:  map[string]string{"condtion": `{"sortCondition":"1","partCondition":""}`},
+		ViewApiPath: "/community/title_view?title=",
+		LikeText:    "点赞",
+	}
+	KindMoments = PostKind{
+		Name:        "moments",
+		Title:       "左邻右舍",
+		ListApiPath: "/community/around_help_list_more",
+		ListParams:  map[string]string{"condition": `{"tag":"","little":"","sortCondition":""}`},
+		ViewApiPath: "/community/around_view?title=",
+		LikeText:    "点赞",
+	}
+	KindCCPPosts = PostKind{
+		Name:        "ccpposts",
+		Title:       "党建园地",
+		ListApiPath: "/community/ccp_list_more",
+		ListParams: map[string]string{
+			"category":  "80",
+			"condition": "{}",
+		},
+		ViewApiPath: "/community/ccp_view?title=",
+		LikeText:    "点赞",
+	}
+	KindProposals = PostKind{
+		Name:        "proposals",
+		Title:       "议事厅",
+		ListApiPath: "/community/proposal_list_more",
+		ListParams:  map[string]string{"condition": "{}"},
+		ViewApiPath: "/community/proposal_view?caseId=",
+		LikeText:    "赞成",
+	}
+)
+
+var (
+	gKindsMtx sync.RWMutex
+	gKinds    []PostKind
+
+	kindNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+func init() {
+	for _, k := range []PostKind{KindNotices, KindMoments, KindCCPPosts, KindProposals} {
+		if err := RegisterPostKind(k); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterPostKind adds a new kind to the registry. The name of the kind must
+// be unique and consist of lower case letters, digits or underscores.
+func RegisterPostKind(k PostKind) error {
+	if !kindNamePattern.MatchString(k.Name) {
+		return fmt.Errorf("invalid post kind name: %q", k.Name)
+	}
+	if k.ListApiPath == "" || k.ViewApiPath == "" || k.LikeText == "" {
+		return fmt.Errorf("incomplete post kind: %s", k.Name)
+	}
+
+	gKindsMtx.Lock()
+	defer gKindsMtx.Unlock()
+	for _, e := range gKinds {
+		if e.Name == k.Name {
+			return fmt.Errorf("post kind already registered: %s", k.Name)
+		}
+	}
+	gKinds = append(gKinds, k)
+	return nil
+}
+
+// PostKinds returns all the registered kinds in the order of registration
+func PostKinds() []PostKind {
+	gKindsMtx.RLock()
+	defer gKindsMtx.RUnlock()
+	return append([]PostKind(nil), gKinds...)
+}
+
+// LookupPostKind returns the registered kind with the name
+func LookupPostKind(name string) (PostKind, bool) {
+	gKindsMtx.RLock()
+	defer gKindsMtx.RUnlock()
+	for _, k := range gKinds {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return PostKind{}, false
+}
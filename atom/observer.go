@@ -0,0 +1,157 @@
+package atom
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Op names the operation an upstream request is sent for
+type Op string
+
+const (
+	OpNegotiate   Op = "negotiate"   // negotiating the qr login connection
+	OpLogin       Op = "login"       // the other requests of the qr login
+	OpCommunities Op = "communities" // fetching the bound communities
+	OpHome        Op = "home"        // reading the current community
+	OpSwitch      Op = "switch"
+	OpList        Op = "list"
+	OpView        Op = "view"
+	OpLike        Op = "like"
+	OpUnlike      Op = "unlike"
+	OpVote        Op = "vote"
+	OpComment     Op = "comment"
+)
+
+// RequestInfo describes an upstream request
+type RequestInfo struct {
+	Op        Op
+	Method    string
+	URL       string
+	Community Community // the current community when the request is sent, zero if none
+}
+
+// ResponseInfo describes the response of an upstream request
+type ResponseInfo struct {
+	RequestInfo
+	StatusCode int // 0 if no response is received
+	Latency    time.Duration
+	// Err is set if the request failed, including the responses rejected by
+	// the client, e.g. with ErrSessionExpired. Non-2xx responses are not
+	// errors here.
+	Err error
+}
+
+// Observer observes the upstream requests of a client, e.g. for logging and
+// metrics. The methods are called on the goroutine sending the request and
+// must be safe for concurrent use. An observer only sees copies of the request
+// and the response, and its panics are recovered and logged, so it cannot
+// affect the requests.
+type Observer interface {
+	// BeforeRequest is called before the request is sent
+	BeforeRequest(ctx context.Context, info RequestInfo)
+	// AfterResponse is called once for each request after the response is
+	// received or the request failed. It may be called without BeforeRequest
+	// if the request could not be built.
+	AfterResponse(ctx context.Context, info ResponseInfo)
+}
+
+// ObserverFuncs adapts functions to an Observer, nil functions are skipped
+type ObserverFuncs struct {
+	Before func(ctx context.Context, info RequestInfo)
+	After  func(ctx context.Context, info ResponseInfo)
+}
+
+func (o ObserverFuncs) BeforeRequest(ctx context.Context, info RequestInfo) {
+	if o.Before != nil {
+		o.Before(ctx, info)
+	}
+}
+
+func (o ObserverFuncs) AfterResponse(ctx context.Context, info ResponseInfo) {
+	if o.After != nil {
+		o.After(ctx, info)
+	}
+}
+
+type opKey struct{}
+
+// r returns a request bound to ctx for the operation
+func (cli *Client) r(ctx context.Context, op Op) *resty.Request {
+	return cli.httpclient.R().SetContext(context.WithValue(ctx, opKey{}, op))
+}
+
+func opOf(ctx context.Context) Op {
+	op, _ := ctx.Value(opKey{}).(Op)
+	return op
+}
+
+// installObservers hooks the observers into the http client. The response
+// hook is the last one, so responses rejected by the other hooks are reported
+// by the error hook instead.
+func (cli *Client) installObservers(observers []Observer) {
+	if len(observers) == 0 {
+		return
+	}
+	cli.httpclient.SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+		info := RequestInfo{
+			Op:        opOf(req.Context()),
+			Method:    req.Method,
+			URL:       req.URL.String(),
+			Community: cli.CurrentCommunity(),
+		}
+		for _, o := range observers {
+			notify(func() { o.BeforeRequest(req.Context(), info) })
+		}
+		return nil
+	})
+	cli.httpclient.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		cli.observeResponse(observers, resp.Request, resp, nil)
+		return nil
+	})
+	cli.httpclient.OnError(func(req *resty.Request, err error) {
+		var resp *resty.Response
+		var respErr *resty.ResponseError
+		if errors.As(err, &respErr) {
+			resp, err = respErr.Response, respErr.Err
+		}
+		cli.observeResponse(observers, req, resp, err)
+	})
+}
+
+func (cli *Client) observeResponse(observers []Observer, req *resty.Request, resp *resty.Response, err error) {
+	info := ResponseInfo{
+		RequestInfo: RequestInfo{
+			Op:        opOf(req.Context()),
+			Method:    req.Method,
+			URL:       req.URL,
+			Community: cli.CurrentCommunity(),
+		},
+		Err: err,
+	}
+	if req.RawRequest != nil {
+		info.URL = req.RawRequest.URL.String()
+	}
+	if resp != nil {
+		info.StatusCode = resp.StatusCode()
+		info.Latency = resp.Time()
+	}
+	for _, o := range observers {
+		notify(func() { o.AfterResponse(req.Context(), info) })
+	}
+}
+
+// notify calls the hook of an observer, a panic in the hook is logged instead
+// of failing the request
+func notify(hook func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("observer panicked: %v", r)
+		}
+	}()
+	hook()
+}
@@ -0,0 +1,101 @@
+package atom_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+// opRecorder records the requests seen by an observer
+type opRecorder struct {
+	mtx       sync.Mutex
+	before    []atom.RequestInfo
+	responses []atom.ResponseInfo
+}
+
+func (r *opRecorder) observer() atom.Observer {
+	return atom.ObserverFuncs{
+		Before: func(ctx context.Context, info atom.RequestInfo) {
+			r.mtx.Lock()
+			defer r.mtx.Unlock()
+			r.before = append(r.before, info)
+		},
+		After: func(ctx context.Context, info atom.ResponseInfo) {
+			r.mtx.Lock()
+			defer r.mtx.Unlock()
+			r.responses = append(r.responses, info)
+		},
+	}
+}
+
+// find returns the last response of the op
+func (r *opRecorder) find(op atom.Op) (atom.ResponseInfo, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for i := len(r.responses) - 1; i >= 0; i-- {
+		if r.responses[i].Op == op {
+			return r.responses[i], true
+		}
+	}
+	return atom.ResponseInfo{}, false
+}
+
+func TestObserver(t *testing.T) {
+	s := newServer(t)
+	rec := &opRecorder{}
+	cli := newClient(s, atom.WithObserver(rec.observer()))
+	login(t, s, cli)
+	if err := cli.SetCurrentCommunityById("m2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Like(context.Background(), atom.KindNotices, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.mtx.Lock()
+	if len(rec.before) != len(rec.responses) {
+		t.Errorf("got %d requests and %d responses", len(rec.before), len(rec.responses))
+	}
+	rec.mtx.Unlock()
+	for _, op := range []atom.Op{atom.OpCommunities, atom.OpSwitch, atom.OpList, atom.OpView, atom.OpLike} {
+		info, ok := rec.find(op)
+		if !ok {
+			t.Errorf("%s: not observed", op)
+			continue
+		}
+		if info.StatusCode != http.StatusOK || info.Err != nil {
+			t.Errorf("%s: got %d %v", op, info.StatusCode, info.Err)
+		}
+	}
+	if info, _ := rec.find(atom.OpLike); info.Community.MemberId != "m2" {
+		t.Errorf("like observed in %q, want m2", info.Community.MemberId)
+	}
+
+	s.ExpireSessions()
+	if _, err := cli.Like(context.Background(), atom.KindNotices, 10); !errors.Is(err, atom.ErrSessionExpired) {
+		t.Fatalf("got %v, want ErrSessionExpired", err)
+	}
+	if info, _ := rec.find(atom.OpList); !errors.Is(info.Err, atom.ErrSessionExpired) {
+		t.Errorf("got %v for the expired session, want ErrSessionExpired", info.Err)
+	}
+}
+
+func TestObserverPanic(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s, atom.WithObserver(atom.ObserverFuncs{
+		Before: func(ctx context.Context, info atom.RequestInfo) { panic("before") },
+		After:  func(ctx context.Context, info atom.ResponseInfo) { panic("after") },
+	}))
+	login(t, s, cli)
+	if _, err := cli.Like(context.Background(), atom.KindNotices, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is not liked")
+	}
+}
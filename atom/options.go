@@ -0,0 +1,241 @@
+package atom
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/publicsuffix"
+)
+
+type clientOptions struct {
+	baseUrl     string
+	wsBaseUrl   string
+	httpClient  *http.Client
+	transport   http.RoundTripper
+	wsDialer    *websocket.Dialer
+	qrTimeout   time.Duration
+	maxInFlight int
+	limiter     *RateLimiter
+	retryPolicy RetryPolicy
+	dryRun      bool
+	filter      *Filter
+	observers   []Observer
+	recorder    *Recorder
+	replayer    *Replayer
+}
+
+// Option configures a Client created by NewClient.
+type Option func(o *clientOptions)
+
+// WithBaseURL sets the base url of the upstream http endpoints. The default
+// is https://www.juweitong.cn/neighbour.
+func WithBaseURL(u string) Option {
+	return func(o *clientOptions) {
+		o.baseUrl = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithWebSocketURL sets the base url of the login websocket endpoint. If not
+// given, it is derived from the base url by replacing http(s) with ws(s).
+func WithWebSocketURL(u string) Option {
+	return func(o *clientOptions) {
+		o.wsBaseUrl = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithHTTPClient makes the client send requests using hc. A cookie jar is
+// created if hc has none, as the login session is kept in cookies.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(o *clientOptions) {
+		o.httpClient = hc
+	}
+}
+
+// WithTransport sets the transport used for sending http requests.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *clientOptions) {
+		o.transport = rt
+	}
+}
+
+// WithWebSocketDialer sets the dialer connecting to the login websocket. By
+// default, the proxy and the tls config of the http transport are used.
+func WithWebSocketDialer(d *websocket.Dialer) Option {
+	return func(o *clientOptions) {
+		o.wsDialer = d
+	}
+}
+
+// WithQRCodeTimeout makes a qr login expire if the qr code is not scanned
+// within d. By default, the login lasts until the upstream expires the code.
+func WithQRCodeTimeout(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.qrTimeout = d
+	}
+}
+
+// WithMaxConcurrency limits the number of posts being liked at the same time
+// to n, shared by all like operations of the client. The default is no limit.
+func WithMaxConcurrency(n int) Option {
+	return func(o *clientOptions) {
+		o.maxInFlight = n
+	}
+}
+
+// WithRateLimit limits the list, view and like requests of the client to rps
+// requests per second. A non-positive rps removes the limit.
+func WithRateLimit(rps float64) Option {
+	return func(o *clientOptions) {
+		o.limiter = nil
+		if rps > 0 {
+			o.limiter = NewRateLimiter(rps)
+		}
+	}
+}
+
+// WithRateLimiter is like WithRateLimit but uses a limiter which may be shared
+// with other clients.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(o *clientOptions) {
+		o.limiter = l
+	}
+}
+
+// WithRetryPolicy sets the policy for retrying failed list, view and like
+// requests. DefaultRetryPolicy is used if not given.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retryPolicy = p
+	}
+}
+
+// WithDryRun makes like operations only report the posts which would be
+// liked. The posts are listed and viewed, but neither liked nor added to the
+// history.
+func WithDryRun() Option {
+	return func(o *clientOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithFilter sets the filter deciding which posts to like. All posts are
+// liked if not given.
+func WithFilter(f *Filter) Option {
+	return func(o *clientOptions) {
+		o.filter = f
+	}
+}
+
+// WithObserver adds an observer of the upstream requests, the observers are
+// called in the order they are added.
+func WithObserver(obs Observer) Option {
+	return func(o *clientOptions) {
+		o.observers = append(o.observers, obs)
+	}
+}
+
+// WithRecorder records the upstream traffic of the client with the recorder.
+// The transport given by the other options is used for sending the requests.
+func WithRecorder(r *Recorder) Option {
+	return func(o *clientOptions) {
+		o.recorder = r
+	}
+}
+
+// WithReplayer serves the upstream traffic of the client from the replayer
+// instead of the upstream. It takes precedence over WithRecorder.
+func WithReplayer(p *Replayer) Option {
+	return func(o *clientOptions) {
+		o.replayer = p
+	}
+}
+
+func (o *clientOptions) newRestyClient() *resty.Client {
+	var c *resty.Client
+	if o.httpClient != nil {
+		hc := *o.httpClient
+		if hc.Jar == nil {
+			hc.Jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		}
+		c = resty.NewWithClient(&hc)
+	} else {
+		c = resty.New()
+	}
+	if o.transport != nil {
+		c.SetTransport(o.transport)
+	}
+	switch {
+	case o.replayer != nil:
+		c.SetTransport(o.replayer)
+	case o.recorder != nil:
+		c.SetTransport(o.recorder.Transport(c.GetClient().Transport))
+	}
+	return c
+}
+
+// webSocketDialer returns the function connecting to the login websocket
+func (o *clientOptions) webSocketDialer() func(ctx context.Context, u string) (wsConn, error) {
+	if o.replayer != nil {
+		return o.replayer.dial
+	}
+	d := o.newWebSocketDialer()
+	if o.recorder != nil {
+		return o.recorder.dialer(d)
+	}
+	return func(ctx context.Context, u string) (wsConn, error) {
+		conn, _, err := d.DialContext(ctx, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// newWebSocketDialer returns the dialer given by WithWebSocketDialer, or a
+// dialer going through the same proxy with the same tls config as the http
+// requests
+func (o *clientOptions) newWebSocketDialer() *websocket.Dialer {
+	if o.wsDialer != nil {
+		return o.wsDialer
+	}
+	d := *websocket.DefaultDialer
+	rt := o.transport
+	if rt == nil && o.httpClient != nil {
+		rt = o.httpClient.Transport
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if t, ok := rt.(*http.Transport); ok {
+		d.Proxy = t.Proxy
+		if t.TLSClientConfig != nil {
+			d.TLSClientConfig = t.TLSClientConfig.Clone()
+		}
+		if t.DialContext != nil {
+			d.NetDialContext = t.DialContext
+		}
+	}
+	return &d
+}
+
+// webSocketBaseUrl returns the base url of the websocket endpoint
+func (o *clientOptions) webSocketBaseUrl() string {
+	if o.wsBaseUrl != "" {
+		return o.wsBaseUrl
+	}
+	if o.baseUrl == kBaseUrl {
+		return kWebSocketBaseUrl
+	}
+	if rest, ok := strings.CutPrefix(o.baseUrl, "https://"); ok {
+		return "wss://" + rest
+	}
+	if rest, ok := strings.CutPrefix(o.baseUrl, "http://"); ok {
+		return "ws://" + rest
+	}
+	return o.baseUrl
+}
@@ -0,0 +1,66 @@
+package parse_test
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexshen/juweitong/atom/parse"
+)
+
+// addFixtures seeds the corpus with the fixture pages
+func addFixtures(f *testing.F) {
+	names, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+func checkError(t *testing.T, err error) {
+	if err != nil && !errors.Is(err, parse.ErrMalformed) {
+		t.Fatalf("got %v, want nil or ErrMalformed", err)
+	}
+}
+
+func FuzzList(f *testing.F) {
+	addFixtures(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		items, err := parse.List(bytes.NewReader(data))
+		checkError(t, err)
+		for _, item := range items {
+			if item.LikeId == "" || item.ViewId == "" {
+				t.Fatalf("got item without ids: %+v", item)
+			}
+		}
+	})
+}
+
+func FuzzView(f *testing.F) {
+	addFixtures(f)
+	base, _ := url.Parse("https://www.juweitong.cn/neighbour/community/notice_view?id=1")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := parse.View(bytes.NewReader(data), base)
+		checkError(t, err)
+	})
+}
+
+func FuzzHome(f *testing.F) {
+	addFixtures(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		home, err := parse.Home(bytes.NewReader(data))
+		checkError(t, err)
+		if err == nil && home == nil {
+			t.Fatal("got no page and no error")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package parse
+
+import (
+	"io"
+	"strings"
+)
+
+// HomePage is the home page of the current member
+type HomePage struct {
+	Community string // name of the current community
+}
+
+// Home parses the home page. An error is returned if the page does not show
+// the current member, which is the case for the login page.
+func Home(r io.Reader) (*HomePage, error) {
+	doc, err := newDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	member := doc.Find("#changeMember").First()
+	if member.Length() == 0 {
+		return nil, &Error{Selector: "#changeMember"}
+	}
+	return &HomePage{Community: strings.TrimSpace(member.Find("span").First().Text())}, nil
+}
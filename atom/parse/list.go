@@ -0,0 +1,75 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const kLikeIdPrefixLen = len("p_")
+
+// ListItem is a post shown in a list page
+type ListItem struct {
+	ViewId       string // the id for reading
+	LikeId       string // the id for liking
+	Title        string
+	Author       string
+	PublishedAt  time.Time // zero if unknown
+	LikeCount    int
+	CommentCount int
+	Liked        bool // whether the post has been liked by the current member
+}
+
+// List parses a list page of posts. Every post of the page must carry a like
+// id and a view id, otherwise an error is returned.
+func List(r io.Reader) ([]ListItem, error) {
+	doc, err := newDocument(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []ListItem
+	doc.Find("body > div").EachWithBreak(func(i int, e *goquery.Selection) bool {
+		idValue, _ := e.Attr("id")
+		if len(idValue) <= kLikeIdPrefixLen {
+			err = &Error{Selector: "body > div[id]", Err: fmt.Errorf("invalid id %q", idValue)}
+			return false
+		}
+		hrefValue, _ := e.Find("a").First().Attr("href")
+		viewId, ok := viewIdFromHref(hrefValue)
+		if !ok {
+			err = &Error{Selector: "a[href]", Err: fmt.Errorf("invalid href %q", hrefValue)}
+			return false
+		}
+		likes := e.Find(".like-count").First()
+		items = append(items, ListItem{
+			ViewId:       viewId,
+			LikeId:       idValue[kLikeIdPrefixLen:],
+			Title:        text(e, ".title"),
+			Author:       text(e, ".author"),
+			PublishedAt:  parseTime(e.Find(".time").First().Text()),
+			LikeCount:    parseCount(likes.Text()),
+			CommentCount: parseCount(e.Find(".comment-count").First().Text()),
+			Liked:        likes.HasClass("liked"),
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// viewIdFromHref extracts the view id from a link of the form
+// javascript:openView('/path?id=<view id>')
+func viewIdFromHref(href string) (string, bool) {
+	begin := strings.IndexByte(href, '=') + 1
+	end := strings.LastIndexByte(href, '\'')
+	if begin == 0 || end <= begin {
+		return "", false
+	}
+	return href[begin:end], true
+}
@@ -0,0 +1,81 @@
+// Package parse parses the html pages of juweitong. The parsers return an
+// error instead of panicking on unexpected markup, so a change of the upstream
+// pages fails the request rather than the process.
+//
+// Only the markup needed for liking is required: the ids of the posts in a
+// list page, the like button of a post page and the community name of the
+// home page. The other fields, including the proposals and the comments, are
+// parsed on a best effort basis and left zero if their markup is not found, as
+// it has not been checked against captured pages yet.
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const kTimeLayout = "2006-01-02 15:04"
+
+// time zone of the timestamps shown on juweitong
+var kTimeZone = time.FixedZone("CST", 8*60*60)
+
+// ErrMalformed is matched by the errors of pages which cannot be parsed, use
+// errors.As with *Error for the details
+var ErrMalformed = errors.New("unexpected upstream page")
+
+// Error is returned if a page cannot be parsed
+type Error struct {
+	Selector string // the selector which is missing or malformed, empty if the page is malformed
+	Err      error  // the underlying error, may be nil
+}
+
+func (e *Error) Error() string {
+	msg := "parse error"
+	if e.Selector != "" {
+		msg = fmt.Sprintf("parse error at %q", e.Selector)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrMalformed
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newDocument(r io.Reader) (*goquery.Document, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, &Error{Err: err}
+	}
+	return doc, nil
+}
+
+// text returns the trimmed text of the first element matching the selector
+func text(s *goquery.Selection, selector string) string {
+	return strings.TrimSpace(s.Find(selector).First().Text())
+}
+
+// parseTime parses a timestamp shown on juweitong, the zero time is returned
+// if s is not a valid timestamp
+func parseTime(s string) time.Time {
+	t, _ := time.ParseInLocation(kTimeLayout, strings.TrimSpace(s), kTimeZone)
+	return t
+}
+
+// parseCount parses a counter, 0 is returned if s is not a number
+func parseCount(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
@@ -0,0 +1,191 @@
+package parse_test
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom/parse"
+)
+
+var cst = time.FixedZone("CST", 8*60*60)
+
+func openFixture(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestList(t *testing.T) {
+	items, err := parse.List(openFixture(t, "list_notices.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []parse.ListItem{
+		{
+			ViewId:       "n1001",
+			LikeId:       "1001",
+			Title:        "关于小区停水的通知",
+			Author:       "物业服务中心",
+			PublishedAt:  time.Date(2023, 6, 1, 9, 30, 0, 0, cst),
+			LikeCount:    12,
+			CommentCount: 3,
+			Liked:        true,
+		},
+		{
+			ViewId:      "n1002",
+			LikeId:      "1002",
+			Title:       "端午节活动报名",
+			Author:      "居委会",
+			PublishedAt: time.Date(2023, 5, 30, 18, 5, 0, 0, cst),
+		},
+		{
+			ViewId:    "n1003",
+			LikeId:    "1003",
+			Title:     "垃圾分类宣传",
+			Author:    "居委会",
+			LikeCount: 5,
+		},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for i := range want {
+		if !items[i].PublishedAt.Equal(want[i].PublishedAt) {
+			t.Errorf("item %d: got time %v, want %v", i, items[i].PublishedAt, want[i].PublishedAt)
+		}
+		items[i].PublishedAt = want[i].PublishedAt
+		if items[i] != want[i] {
+			t.Errorf("item %d: got %+v, want %+v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestListEmpty(t *testing.T) {
+	items, err := parse.List(openFixture(t, "list_empty.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("got %d items, want none", len(items))
+	}
+}
+
+func TestListMalformed(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		selector string
+	}{
+		{"list_unexpected_div.html", "body > div[id]"},
+		{"list_bad_href.html", "a[href]"},
+		{"login.html", "a[href]"},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			_, err := parse.List(openFixture(t, test.fixture))
+			if !errors.Is(err, parse.ErrMalformed) {
+				t.Fatalf("got %v, want ErrMalformed", err)
+			}
+			var perr *parse.Error
+			if !errors.As(err, &perr) || perr.Selector != test.selector {
+				t.Fatalf("got %v, want error at %q", err, test.selector)
+			}
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	base, _ := url.Parse("https://www.juweitong.cn/neighbour/community/notice_view?id=n1001")
+	page, err := parse.View(openFixture(t, "view_notice.html"), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Title != "关于小区停水的通知" || page.Publisher != "物业服务中心" {
+		t.Errorf("got title %q publisher %q", page.Title, page.Publisher)
+	}
+	if !page.PublishedAt.Equal(time.Date(2023, 6, 1, 9, 30, 0, 0, cst)) {
+		t.Errorf("got time %v", page.PublishedAt)
+	}
+	if page.Text != "因管道检修，6月2日 9:00-17:00 停水。" {
+		t.Errorf("got text %q", page.Text)
+	}
+	wantImages := []string{
+		"https://www.juweitong.cn/neighbour/upload/notice.png",
+		"https://cdn.example.com/a.jpg",
+	}
+	if !reflect.DeepEqual(page.Images, wantImages) {
+		t.Errorf("got images %v, want %v", page.Images, wantImages)
+	}
+	wantAttachments := []parse.Attachment{
+		{Name: "停水通知.pdf", URL: "https://www.juweitong.cn/neighbour/community/upload/notice.pdf"},
+	}
+	if !reflect.DeepEqual(page.Attachments, wantAttachments) {
+		t.Errorf("got attachments %v, want %v", page.Attachments, wantAttachments)
+	}
+	if page.LikeText != "点赞" {
+		t.Errorf("got like text %q", page.LikeText)
+	}
+	if page.Proposal != nil {
+		t.Errorf("got proposal %+v on a notice", page.Proposal)
+	}
+	if len(page.Comments) != 2 {
+		t.Fatalf("got %d comments, want 2", len(page.Comments))
+	}
+	if c := page.Comments[1]; c.Id != "c2" || c.ReplyTo != "c1" || c.Author != "物业服务中心" || c.Text != "谢谢配合" {
+		t.Errorf("got comment %+v", c)
+	}
+}
+
+func TestViewProposal(t *testing.T) {
+	page, err := parse.View(openFixture(t, "view_proposal.html"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := page.Proposal
+	if p == nil {
+		t.Fatal("proposal not found")
+	}
+	if p.CaseId != "5001" || p.Status != "closed" {
+		t.Errorf("got case %q status %q", p.CaseId, p.Status)
+	}
+	if !p.Deadline.Equal(time.Date(2023, 5, 15, 23, 59, 0, 0, cst)) {
+		t.Errorf("got deadline %v", p.Deadline)
+	}
+	want := []parse.Choice{
+		{Id: "1", Text: "同意", Votes: 42, Chosen: true},
+		{Id: "2", Text: "反对", Votes: 7},
+		{Id: "3", Text: "弃权", Votes: 3},
+	}
+	if !reflect.DeepEqual(p.Choices, want) {
+		t.Errorf("got choices %+v, want %+v", p.Choices, want)
+	}
+	if page.LikeText != "已赞成" {
+		t.Errorf("got like text %q", page.LikeText)
+	}
+	if len(page.Comments) != 0 {
+		t.Errorf("got %d comments, want none", len(page.Comments))
+	}
+}
+
+func TestHome(t *testing.T) {
+	home, err := parse.Home(openFixture(t, "home.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home.Community != "阳光花园" {
+		t.Errorf("got community %q", home.Community)
+	}
+
+	_, err = parse.Home(openFixture(t, "login.html"))
+	if !errors.Is(err, parse.ErrMalformed) {
+		t.Errorf("got %v for the login page, want ErrMalformed", err)
+	}
+}
@@ -0,0 +1,133 @@
+package parse
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Attachment is a file attached to a post
+type Attachment struct {
+	Name string
+	URL  string
+}
+
+// Choice is a choice which can be voted for a proposal
+type Choice struct {
+	Id     string
+	Text   string
+	Votes  int
+	Chosen bool // whether the current member has voted for this choice
+}
+
+// Proposal is the voting part of the page of a proposal
+type Proposal struct {
+	CaseId   string    // the id for voting
+	Status   string    // open, closed or passed, empty if not shown
+	Deadline time.Time // zero if unknown
+	Choices  []Choice
+}
+
+// Comment is a comment shown on the page of a post
+type Comment struct {
+	Id          string
+	Author      string
+	Text        string
+	PublishedAt time.Time // zero if unknown
+	ReplyTo     string    // id of the replied comment, empty if replying the post
+}
+
+// PostPage is the page of a post
+type PostPage struct {
+	Title       string
+	Publisher   string
+	PublishedAt time.Time // zero if unknown
+	Text        string    // body in plain text
+	HTML        string    // body in html
+	Images      []string  // urls of the images in the body
+	Attachments []Attachment
+	LikeText    string    // text of the like button, empty if there is no button
+	Proposal    *Proposal // nil if the post is not a proposal
+	Comments    []Comment // the earliest first
+}
+
+// View parses the page of a post. The urls of the images and the attachments
+// are resolved against base, they are kept as is if base is nil.
+func View(r io.Reader, base *url.URL) (*PostPage, error) {
+	doc, err := newDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(ref string) string {
+		ref = strings.TrimSpace(ref)
+		if base == nil {
+			return ref
+		}
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return u.String()
+	}
+
+	content := doc.Find(".content").First()
+	html, err := content.Html()
+	if err != nil {
+		return nil, &Error{Selector: ".content", Err: err}
+	}
+	page := &PostPage{
+		Title:       text(doc.Selection, ".title"),
+		Publisher:   text(doc.Selection, ".publisher"),
+		PublishedAt: parseTime(doc.Find(".time").First().Text()),
+		Text:        strings.TrimSpace(content.Text()),
+		HTML:        strings.TrimSpace(html),
+		LikeText:    doc.Find("span#cmdLike").First().Text(),
+		Proposal:    parseProposal(doc),
+	}
+	content.Find("img[src]").Each(func(i int, e *goquery.Selection) {
+		src, _ := e.Attr("src")
+		page.Images = append(page.Images, resolve(src))
+	})
+	doc.Find("a.attachment[href]").Each(func(i int, e *goquery.Selection) {
+		href, _ := e.Attr("href")
+		page.Attachments = append(page.Attachments, Attachment{
+			Name: strings.TrimSpace(e.Text()),
+			URL:  resolve(href),
+		})
+	})
+	doc.Find(".comments .comment").Each(func(i int, e *goquery.Selection) {
+		id, _ := e.Attr("data-id")
+		replyTo, _ := e.Attr("data-reply")
+		page.Comments = append(page.Comments, Comment{
+			Id:          id,
+			Author:      text(e, ".comment-author"),
+			Text:        text(e, ".comment-text"),
+			PublishedAt: parseTime(e.Find(".comment-time").First().Text()),
+			ReplyTo:     replyTo,
+		})
+	})
+	return page, nil
+}
+
+func parseProposal(doc *goquery.Document) *Proposal {
+	sel := doc.Find(".proposal").First()
+	if sel.Length() == 0 {
+		return nil
+	}
+	p := &Proposal{Deadline: parseTime(sel.Find(".deadline").First().Text())}
+	p.CaseId, _ = sel.Attr("data-case")
+	p.Status, _ = sel.Attr("data-status")
+	sel.Find(".choice").Each(func(i int, e *goquery.Selection) {
+		id, _ := e.Attr("data-choice")
+		p.Choices = append(p.Choices, Choice{
+			Id:     id,
+			Text:   text(e, ".choice-text"),
+			Votes:  parseCount(e.Find(".vote-count").First().Text()),
+			Chosen: e.HasClass("voted"),
+		})
+	})
+	return p
+}
@@ -0,0 +1,186 @@
+package atom
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+// Pool manages clients of several accounts, or of several sessions of an
+// account, which share a rate limiter and a history
+type Pool struct {
+	mtx     sync.Mutex
+	clients []*Client
+	history LikedPostsHistory
+	opts    []Option
+}
+
+// NewPool creates a pool whose clients are created with the history and the
+// options. The rate limiter given by the options is shared by all clients.
+func NewPool(history LikedPostsHistory, opts ...Option) *Pool {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	opts = append([]Option(nil), opts...)
+	if o.limiter != nil {
+		// WithRateLimit creates a limiter on each call, pin the one created
+		opts = append(opts, WithRateLimiter(o.limiter))
+	}
+	return &Pool{
+		history: history,
+		opts:    opts,
+	}
+}
+
+// NewClient creates a client in the pool, the client needs to be logged in
+// before running a plan
+func (p *Pool) NewClient() *Client {
+	c := NewClient(p.history, p.opts...)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.clients = append(p.clients, c)
+	return c
+}
+
+// Remove removes the client from the pool
+func (p *Pool) Remove(c *Client) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.clients = lo.Without(p.clients, c)
+}
+
+// Clients returns the clients in the pool
+func (p *Pool) Clients() []*Client {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return append([]*Client(nil), p.clients...)
+}
+
+// LikePlan tells what to like for each account
+type LikePlan struct {
+	Kinds       []PostKind // all registered kinds if empty
+	Communities []string   // names of the communities, all communities if empty
+	Options     ListOptions
+}
+
+// PlanResult is the result of liking a kind of posts in a community
+type PlanResult struct {
+	Community Community
+	Kind      PostKind
+	Report    *LikeReport // nil if Err is set
+	Err       error
+}
+
+// AccountReport is the result of running a plan on an account
+type AccountReport struct {
+	Client  *Client
+	Results []PlanResult
+	Err     error // set if the plan could not run on the account
+}
+
+// Run runs the plan on all the clients in parallel and returns the reports in
+// the order of the clients. Clients not logged in are reported with
+// ErrNotLoggedIn.
+//
+// The upstream keeps the current community per session, so a client likes one
+// community at a time. Clients logged in to the same account by separate qr
+// logins have separate sessions, they split the communities of the plan
+// between them and like them at the same time. Clients sharing a session, e.g.
+// restored from the same Session, must not be in the same pool as they would
+// switch the community of each other.
+func (p *Pool) Run(ctx context.Context, plan LikePlan) []AccountReport {
+	kinds := plan.Kinds
+	if len(kinds) == 0 {
+		kinds = PostKinds()
+	}
+
+	clients := p.Clients()
+	shares := shareCommunities(clients, plan)
+	reports := make([]AccountReport, len(clients))
+	wg := sync.WaitGroup{}
+	for i, c := range clients {
+		wg.Add(1)
+		go func(report *AccountReport, c *Client, communities []Community) {
+			defer wg.Done()
+			report.Client = c
+			report.Results, report.Err = runPlan(ctx, c, plan, kinds, communities)
+		}(&reports[i], c, shares[i])
+	}
+	wg.Wait()
+	return reports
+}
+
+// shareCommunities returns the communities of the plan each client likes. The
+// communities of an account are dealt in turn to its logged in clients.
+func shareCommunities(clients []*Client, plan LikePlan) [][]Community {
+	shares := make([][]Community, len(clients))
+	var accounts []string
+	sessions := make(map[string][]int)
+	for i, c := range clients {
+		if !c.IsLoggedIn() {
+			continue
+		}
+		id := c.Id()
+		if id == "" {
+			// cannot tell the account, like all its communities
+			id = fmt.Sprintf("#%d", i)
+		}
+		if _, ok := sessions[id]; !ok {
+			accounts = append(accounts, id)
+		}
+		sessions[id] = append(sessions[id], i)
+	}
+
+	for _, id := range accounts {
+		indices := sessions[id]
+		communities := clients[indices[0]].Communities()
+		if len(plan.Communities) != 0 {
+			communities = lo.Filter(communities, func(e Community, i int) bool {
+				return lo.Contains(plan.Communities, e.Name)
+			})
+		}
+		for i, community := range communities {
+			j := indices[i%len(indices)]
+			shares[j] = append(shares[j], community)
+		}
+	}
+	return shares
+}
+
+func runPlan(ctx context.Context, c *Client, plan LikePlan, kinds []PostKind, communities []Community) ([]PlanResult, error) {
+	if err := c.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+
+	var results []PlanResult
+	for _, community := range communities {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		err := c.WithCommunity(ctx, community.MemberId, func(ctx context.Context) error {
+			for _, kind := range kinds {
+				report, err := c.LikePaged(ctx, kind, plan.Options)
+				results = append(results, PlanResult{
+					Community: community,
+					Kind:      kind,
+					Report:    report,
+					Err:       err,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			for _, kind := range kinds {
+				results = append(results, PlanResult{
+					Community: community,
+					Kind:      kind,
+					Err:       fmt.Errorf("failed to switch to %s: %w", community.Name, err),
+				})
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,93 @@
+package atom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+func TestPoolRun(t *testing.T) {
+	s := newServer(t)
+	pool := atom.NewPool(atom.NullLikedPostsHistory{},
+		atom.WithBaseURL(s.URL()),
+		atom.WithRetryPolicy(atom.NoRetry),
+		atom.WithRateLimit(1000))
+	loggedIn := pool.NewClient()
+	login(t, s, loggedIn)
+	loggedOut := pool.NewClient()
+
+	reports := pool.Run(context.Background(), atom.LikePlan{
+		Kinds:   []atom.PostKind{atom.KindNotices},
+		Options: atom.ListOptions{MaxPosts: 10},
+	})
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2", len(reports))
+	}
+
+	if reports[0].Client != loggedIn || reports[0].Err != nil {
+		t.Fatalf("unexpected report of the logged in client: %+v", reports[0])
+	}
+	if len(reports[0].Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(reports[0].Results))
+	}
+	for _, res := range reports[0].Results {
+		if res.Err != nil {
+			t.Errorf("%s: %v", res.Community.Name, res.Err)
+			continue
+		}
+		if n := res.Report.Count(atom.OutcomeLiked); n != 1 {
+			t.Errorf("%s: got %d liked, want 1", res.Community.Name, n)
+		}
+	}
+	for _, member := range []string{"m1", "m2"} {
+		if !s.IsLiked(member, atomtest.KindNotices, member+"-1") {
+			t.Errorf("%s-1 is not liked", member)
+		}
+	}
+
+	if reports[1].Client != loggedOut || !errors.Is(reports[1].Err, atom.ErrNotLoggedIn) {
+		t.Errorf("got %v for the client not logged in, want ErrNotLoggedIn", reports[1].Err)
+	}
+}
+
+func TestPoolRunSessions(t *testing.T) {
+	s := newServer(t)
+	pool := atom.NewPool(atom.NullLikedPostsHistory{},
+		atom.WithBaseURL(s.URL()),
+		atom.WithRetryPolicy(atom.NoRetry))
+	// two qr logins of the same account
+	first := pool.NewClient()
+	login(t, s, first)
+	second := pool.NewClient()
+	login(t, s, second)
+
+	reports := pool.Run(context.Background(), atom.LikePlan{
+		Kinds:   []atom.PostKind{atom.KindNotices},
+		Options: atom.ListOptions{MaxPosts: 10},
+	})
+	liked := make(map[string]int)
+	for i, report := range reports {
+		if report.Err != nil {
+			t.Fatalf("client %d: %v", i, report.Err)
+		}
+		if len(report.Results) != 1 {
+			t.Fatalf("client %d: got %d results, want one community each", i, len(report.Results))
+		}
+		res := report.Results[0]
+		if res.Err != nil {
+			t.Fatalf("client %d: %s: %v", i, res.Community.Name, res.Err)
+		}
+		liked[res.Community.MemberId] += res.Report.Count(atom.OutcomeLiked)
+	}
+	for _, member := range []string{"m1", "m2"} {
+		if liked[member] != 1 {
+			t.Errorf("%s: got %d liked, want 1", member, liked[member])
+		}
+		if !s.IsLiked(member, atomtest.KindNotices, member+"-1") {
+			t.Errorf("%s-1 is not liked", member)
+		}
+	}
+}
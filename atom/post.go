@@ -0,0 +1,83 @@
+package atom
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexshen/juweitong/atom/parse"
+	"github.com/samber/lo"
+)
+
+// Post is a post shown in the list of a kind. Only the ids are sure to be
+// set, the other fields are zero if not found on the page.
+type Post struct {
+	ViewId       string // the id for reading
+	LikeId       string // the id for liking
+	Title        string
+	Author       string
+	PublishedAt  time.Time // zero if unknown
+	LikeCount    int
+	CommentCount int
+	Liked        bool // whether the post has been liked by the current member
+}
+
+// ListPosts returns the posts of the kind in the current community without
+// liking them
+func (cli *Client) ListPosts(ctx context.Context, kind PostKind, opts ListOptions) ([]Post, error) {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+	defer cli.holdCommunity(ctx)()
+	return cli.listPosts(ctx, kind, opts)
+}
+
+// Attachment is a file attached to a post
+type Attachment struct {
+	Name string
+	URL  string
+}
+
+// PostDetail is the content of a post. Only ViewId is sure to be set, the
+// other fields are zero if not found on the page.
+type PostDetail struct {
+	ViewId      string
+	Title       string
+	Publisher   string
+	PublishedAt time.Time // zero if unknown
+	Text        string    // body in plain text
+	HTML        string    // body in html
+	Images      []string  // urls of the images in the body
+	Attachments []Attachment
+	Liked       bool // whether the post has been liked by the current member
+}
+
+// GetPost returns the content of the post with the view id in the current
+// community
+func (cli *Client) GetPost(ctx context.Context, kind PostKind, viewId string) (*PostDetail, error) {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+	defer cli.holdCommunity(ctx)()
+	page, _, err := cli.getPostPage(ctx, kind.ViewApiPath, viewId)
+	if err != nil {
+		return nil, err
+	}
+	return newPostDetail(page, kind, viewId), nil
+}
+
+// newPostDetail returns the detail of the post with the view id on the page
+func newPostDetail(page *parse.PostPage, kind PostKind, viewId string) *PostDetail {
+	return &PostDetail{
+		ViewId:      viewId,
+		Title:       page.Title,
+		Publisher:   page.Publisher,
+		PublishedAt: page.PublishedAt,
+		Text:        page.Text,
+		HTML:        page.HTML,
+		Images:      page.Images,
+		Attachments: lo.Map(page.Attachments, func(e parse.Attachment, i int) Attachment {
+			return Attachment(e)
+		}),
+		Liked: page.LikeText != "" && page.LikeText != kind.LikeText,
+	}
+}
@@ -0,0 +1,113 @@
+package atom_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+func TestListPosts(t *testing.T) {
+	s := newServer(t)
+	s.AddPost("m1", atomtest.KindNotices, atomtest.Post{
+		ViewId:   "m1-v3",
+		LikeId:   "m1-3",
+		Title:    "垃圾分类宣传",
+		Author:   "居委会",
+		Time:     published.Add(time.Hour),
+		Likes:    5,
+		Comments: 2,
+	})
+	cli := newClient(s)
+	login(t, s, cli)
+
+	posts, err := cli.ListPosts(context.Background(), atom.KindNotices, atom.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range posts {
+		posts[i].PublishedAt = posts[i].PublishedAt.UTC()
+	}
+	want := []atom.Post{
+		{
+			ViewId:       "m1-v3",
+			LikeId:       "m1-3",
+			Title:        "垃圾分类宣传",
+			Author:       "居委会",
+			PublishedAt:  published.Add(time.Hour),
+			LikeCount:    5,
+			CommentCount: 2,
+		},
+		{
+			ViewId:      "m1-v1",
+			LikeId:      "m1-1",
+			Title:       "停水通知",
+			Author:      "物业服务中心",
+			PublishedAt: published,
+		},
+		{
+			ViewId:      "m1-v2",
+			LikeId:      "m1-2",
+			Title:       "端午节活动报名",
+			Author:      "居委会",
+			PublishedAt: published.Add(-time.Hour),
+			LikeCount:   1,
+			Liked:       true,
+		},
+	}
+	if !reflect.DeepEqual(posts, want) {
+		t.Fatalf("got %+v, want %+v", posts, want)
+	}
+	// listing likes nothing
+	if s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is liked")
+	}
+}
+
+func TestGetPost(t *testing.T) {
+	s := newServer(t)
+	s.AddPost("m1", atomtest.KindNotices, atomtest.Post{
+		ViewId:      "m1-v3",
+		LikeId:      "m1-3",
+		Title:       "停水通知",
+		Author:      "物业服务中心",
+		Time:        published,
+		Content:     `<p>明日停水</p><img src="img/a.png"/>`,
+		Attachments: []atomtest.Attachment{{Name: "通知.pdf", URL: "upload/a.pdf"}},
+	})
+	cli := newClient(s)
+	login(t, s, cli)
+
+	post, err := cli.GetPost(context.Background(), atom.KindNotices, "m1-v3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.ViewId != "m1-v3" || post.Title != "停水通知" || post.Publisher != "物业服务中心" ||
+		!post.PublishedAt.Equal(published) || post.Text != "明日停水" || post.Liked {
+		t.Errorf("unexpected post: %+v", post)
+	}
+	if !strings.Contains(post.HTML, "<p>明日停水</p>") {
+		t.Errorf("unexpected html %q", post.HTML)
+	}
+	// the urls are resolved against the page
+	if len(post.Images) != 1 || !strings.HasPrefix(post.Images[0], "http://") ||
+		!strings.HasSuffix(post.Images[0], "/img/a.png") {
+		t.Errorf("unexpected images %v", post.Images)
+	}
+	if len(post.Attachments) != 1 || post.Attachments[0].Name != "通知.pdf" ||
+		!strings.HasSuffix(post.Attachments[0].URL, "/upload/a.pdf") {
+		t.Errorf("unexpected attachments %v", post.Attachments)
+	}
+
+	post, err = cli.GetPost(context.Background(), atom.KindNotices, "m1-v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !post.Liked {
+		t.Error("m1-2 is not liked")
+	}
+}
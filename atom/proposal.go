@@ -0,0 +1,163 @@
+package atom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexshen/juweitong/atom/parse"
+	"github.com/samber/lo"
+)
+
+// The vote endpoint and the proposal markup are not confirmed against
+// juweitong yet, see atom/parse/testdata/README.md
+const (
+	kVoteApiPath = "/community/proposal_vote"
+	kAbstainText = "弃权"
+)
+
+var (
+	ErrProposalClosed  = errors.New("proposal is closed")
+	ErrInvalidChoice   = errors.New("invalid vote choice")
+	ErrNoAbstainChoice = errors.New("proposal cannot be abstained")
+)
+
+// ProposalStatus is the voting status of a proposal
+type ProposalStatus int
+
+const (
+	// ProposalOpen means the proposal is accepting votes
+	ProposalOpen ProposalStatus = iota
+	// ProposalClosed means the voting has ended without the proposal passed
+	ProposalClosed
+	// ProposalPassed means the voting has ended with the proposal passed
+	ProposalPassed
+)
+
+func (s ProposalStatus) String() string {
+	switch s {
+	case ProposalOpen:
+		return "open"
+	case ProposalClosed:
+		return "closed"
+	case ProposalPassed:
+		return "passed"
+	}
+	return fmt.Sprintf("ProposalStatus(%d)", int(s))
+}
+
+// VoteChoice is a choice which can be voted for a proposal
+type VoteChoice struct {
+	Id     string
+	Text   string
+	Votes  int
+	Chosen bool // whether the current member has voted for this choice
+}
+
+// Proposal is a proposal with its voting information
+type Proposal struct {
+	PostDetail
+	CaseId   string // the id for voting
+	Status   ProposalStatus
+	Deadline time.Time // zero if unknown
+	Choices  []VoteChoice
+}
+
+// Voted returns the choice voted by the current member
+func (p *Proposal) Voted() (VoteChoice, bool) {
+	for _, c := range p.Choices {
+		if c.Chosen {
+			return c, true
+		}
+	}
+	return VoteChoice{}, false
+}
+
+// GetProposal returns the proposal with the view id in the current community
+func (cli *Client) GetProposal(ctx context.Context, viewId string) (*Proposal, error) {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+	defer cli.holdCommunity(ctx)()
+	page, _, err := cli.getPostPage(ctx, KindProposals.ViewApiPath, viewId)
+	if err != nil {
+		return nil, err
+	}
+	if page.Proposal == nil {
+		return nil, &ParseError{Selector: ".proposal", Err: fmt.Errorf("not a proposal: %s", viewId)}
+	}
+	status, err := proposalStatus(page.Proposal.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proposal{
+		PostDetail: *newPostDetail(page, KindProposals, viewId),
+		CaseId:     page.Proposal.CaseId,
+		Status:     status,
+		Deadline:   page.Proposal.Deadline,
+		Choices: lo.Map(page.Proposal.Choices, func(e parse.Choice, i int) VoteChoice {
+			return VoteChoice(e)
+		}),
+	}, nil
+}
+
+// Vote casts the vote for the choice with the id on the proposal
+func (cli *Client) Vote(ctx context.Context, p *Proposal, choiceId string) error {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return err
+	}
+	defer cli.holdCommunity(ctx)()
+	if p.Status != ProposalOpen {
+		return ErrProposalClosed
+	}
+	if !hasChoice(p, choiceId) {
+		return ErrInvalidChoice
+	}
+	if err := cli.throttle(ctx); err != nil {
+		return err
+	}
+	// not retried as a vote which has reached the server may not be cast again
+	_, err := getWithJsonError(cli.r(ctx, OpVote).SetQueryParams(map[string]string{
+		"caseId": p.CaseId,
+		"choice": choiceId,
+	}), kVoteApiPath)
+	if err != nil {
+		return fmt.Errorf("vote error: %w, %s", err, p.CaseId)
+	}
+	return nil
+}
+
+// Abstain abstains from voting on the proposal
+func (cli *Client) Abstain(ctx context.Context, p *Proposal) error {
+	for _, c := range p.Choices {
+		if c.Text == kAbstainText {
+			return cli.Vote(ctx, p, c.Id)
+		}
+	}
+	return ErrNoAbstainChoice
+}
+
+func hasChoice(p *Proposal, choiceId string) bool {
+	for _, c := range p.Choices {
+		if c.Id == choiceId {
+			return true
+		}
+	}
+	return false
+}
+
+// proposalStatus returns the status shown on the page of a proposal, a
+// missing or unknown status is an error so it is never taken as open
+func proposalStatus(s string) (ProposalStatus, error) {
+	switch s {
+	case "open":
+		return ProposalOpen, nil
+	case "closed":
+		return ProposalClosed, nil
+	case "passed":
+		return ProposalPassed, nil
+	}
+	return 0, &ParseError{Selector: ".proposal[data-status]", Err: fmt.Errorf("unknown status %q", s)}
+}
@@ -0,0 +1,99 @@
+package atom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+// addProposal publishes a proposal of the status in m1
+func addProposal(s *atomtest.Server, likeId string, status string) {
+	s.AddPost("m1", atomtest.KindProposals, atomtest.Post{
+		ViewId: "v" + likeId,
+		LikeId: likeId,
+		Title:  "关于增设充电桩的提议",
+		Author: "业委会",
+		Time:   published,
+		Proposal: &atomtest.Proposal{
+			Status:   status,
+			Deadline: published.Add(7 * 24 * time.Hour),
+			Choices: []atomtest.VoteChoice{
+				{Id: "1", Text: "赞成", Votes: 3},
+				{Id: "2", Text: "反对"},
+				{Id: "3", Text: "弃权"},
+			},
+		},
+	})
+}
+
+func TestLikeClosedProposal(t *testing.T) {
+	s := newServer(t)
+	addProposal(s, "open", "open")
+	addProposal(s, "closed", "closed")
+	addProposal(s, "passed", "passed")
+	cli := newClient(s)
+	login(t, s, cli)
+
+	report, err := cli.Like(context.Background(), atom.KindProposals, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]atom.LikeOutcome{
+		"open":   atom.OutcomeLiked,
+		"closed": atom.OutcomeClosed,
+		"passed": atom.OutcomeClosed,
+	}
+	for _, p := range report.Posts {
+		if p.Outcome != want[p.LikeId] {
+			t.Errorf("%s: got %v, want %v", p.LikeId, p.Outcome, want[p.LikeId])
+		}
+	}
+	if len(report.Posts) != len(want) {
+		t.Errorf("got %d posts, want %d", len(report.Posts), len(want))
+	}
+}
+
+func TestVote(t *testing.T) {
+	s := newServer(t)
+	addProposal(s, "open", "open")
+	addProposal(s, "closed", "closed")
+	cli := newClient(s)
+	login(t, s, cli)
+	ctx := context.Background()
+
+	p, err := cli.GetProposal(ctx, "vopen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != atom.ProposalOpen || len(p.Choices) != 3 || p.Choices[0].Votes != 3 ||
+		!p.Deadline.Equal(published.Add(7*24*time.Hour)) {
+		t.Fatalf("unexpected proposal: %+v", p)
+	}
+	if err := cli.Vote(ctx, p, "4"); !errors.Is(err, atom.ErrInvalidChoice) {
+		t.Fatalf("got %v, want ErrInvalidChoice", err)
+	}
+	if err := cli.Abstain(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = cli.GetProposal(ctx, "vopen"); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := p.Voted(); !ok || c.Id != "3" || c.Votes != 1 {
+		t.Errorf("got voted %+v, want the abstain choice", c)
+	}
+
+	closed, err := cli.GetProposal(ctx, "vclosed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.Status != atom.ProposalClosed {
+		t.Fatalf("got status %v, want closed", closed.Status)
+	}
+	if err := cli.Vote(ctx, closed, "1"); !errors.Is(err, atom.ErrProposalClosed) {
+		t.Fatalf("got %v, want ErrProposalClosed", err)
+	}
+}
@@ -0,0 +1,50 @@
+package atom
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out upstream requests evenly. A limiter can be shared by
+// several clients.
+type RateLimiter struct {
+	mtx      sync.Mutex
+	interval time.Duration
+	next     time.Time // the earliest time of the next request
+}
+
+// NewRateLimiter returns a limiter allowing rps requests per second, there is
+// no limit if rps is not positive
+func NewRateLimiter(rps float64) *RateLimiter {
+	l := &RateLimiter{}
+	if rps > 0 {
+		l.interval = time.Duration(float64(time.Second) / rps)
+	}
+	return l
+}
+
+// Wait blocks until the next request is allowed or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mtx.Lock()
+	now := time.Now()
+	t := l.next
+	if t.Before(now) {
+		t = now
+	}
+	l.next = t.Add(l.interval)
+	l.mtx.Unlock()
+
+	d := t.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
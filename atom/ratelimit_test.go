@@ -0,0 +1,68 @@
+package atom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := atom.NewRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first request is not delayed, the other four are 10ms apart
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("5 requests took %v, want at least 40ms", d)
+	}
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	l := atom.NewRateLimiter(0.1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("canceled wait took %v", d)
+	}
+}
+
+func TestRateLimiterNoLimit(t *testing.T) {
+	for _, rps := range []float64{0, -1} {
+		l := atom.NewRateLimiter(rps)
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			if err := l.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if d := time.Since(start); d > 100*time.Millisecond {
+			t.Errorf("rps %v: 100 requests took %v", rps, d)
+		}
+	}
+}
+
+func TestWithRateLimitNoLimit(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s, atom.WithRateLimit(0))
+	login(t, s, cli)
+	if _, err := cli.Like(context.Background(), atom.KindNotices, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is not liked")
+	}
+}
@@ -0,0 +1,114 @@
+package atom
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LikeOutcome tells what happened to a visited post
+type LikeOutcome int
+
+const (
+	// OutcomeLiked means the post has been liked by this run
+	OutcomeLiked LikeOutcome = iota
+	// OutcomeAlreadyLiked means the post had already been liked upstream
+	OutcomeAlreadyLiked
+	// OutcomeSkipped means the post was skipped as it is in the history
+	OutcomeSkipped
+	// OutcomeFailed means the post could not be liked, see PostResult.Err
+	OutcomeFailed
+	// OutcomeClosed means the post was not liked as the proposal is closed
+	OutcomeClosed
+	// OutcomeUnliked means the like of the post has been reverted
+	OutcomeUnliked
+	// OutcomeWouldLike means the post would be liked if not in dry run
+	OutcomeWouldLike
+	// OutcomeFiltered means the post was skipped by the filter
+	OutcomeFiltered
+)
+
+var kOutcomes = []LikeOutcome{
+	OutcomeLiked,
+	OutcomeWouldLike,
+	OutcomeAlreadyLiked,
+	OutcomeSkipped,
+	OutcomeFiltered,
+	OutcomeClosed,
+	OutcomeUnliked,
+	OutcomeFailed,
+}
+
+func (o LikeOutcome) String() string {
+	switch o {
+	case OutcomeLiked:
+		return "liked"
+	case OutcomeAlreadyLiked:
+		return "already_liked"
+	case OutcomeSkipped:
+		return "skipped"
+	case OutcomeFailed:
+		return "failed"
+	case OutcomeClosed:
+		return "closed"
+	case OutcomeUnliked:
+		return "unliked"
+	case OutcomeWouldLike:
+		return "would_like"
+	case OutcomeFiltered:
+		return "filtered"
+	}
+	return fmt.Sprintf("LikeOutcome(%d)", int(o))
+}
+
+// PostResult is the outcome of a single visited post
+type PostResult struct {
+	ViewId  string
+	LikeId  string
+	Outcome LikeOutcome
+	Err     error // set if Outcome is OutcomeFailed
+	Retries int   // number of retried requests
+}
+
+// LikeReport is the result of a like operation
+type LikeReport struct {
+	Posts    []PostResult // visited posts in the order of the list
+	Duration time.Duration
+}
+
+// Count returns the number of posts with the outcome o
+func (r *LikeReport) Count(o LikeOutcome) int {
+	var n int
+	for _, p := range r.Posts {
+		if p.Outcome == o {
+			n++
+		}
+	}
+	return n
+}
+
+// Errors returns the errors of the failed posts
+func (r *LikeReport) Errors() []error {
+	var errs []error
+	for _, p := range r.Posts {
+		if p.Err != nil {
+			errs = append(errs, p.Err)
+		}
+	}
+	return errs
+}
+
+// String summarizes the outcomes which happened, e.g.
+// "liked 3, skipped 7 in 1.2s"
+func (r *LikeReport) String() string {
+	var counts []string
+	for _, o := range kOutcomes {
+		if n := r.Count(o); n != 0 {
+			counts = append(counts, fmt.Sprintf("%s %d", strings.ReplaceAll(o.String(), "_", " "), n))
+		}
+	}
+	if len(counts) == 0 {
+		counts = append(counts, "no posts")
+	}
+	return fmt.Sprintf("%s in %v", strings.Join(counts, ", "), r.Duration.Round(time.Millisecond))
+}
@@ -0,0 +1,100 @@
+package atom
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed list, view and like requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one, retrying
+	// is disabled if it is less than 2
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, it doubles after each
+	// retry up to MaxBackoff. A random jitter of up to half of the wait is
+	// subtracted.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Retryable tells if a failed request should be retried, DefaultRetryable
+	// is used if nil
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy is the policy used by clients unless WithRetryPolicy is given
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// NoRetry disables retrying
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// DefaultRetryable returns true for timeouts, broken connections, 5xx and 429
+// responses
+func DefaultRetryable(err error) bool {
+	var statusErr *UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 || statusErr.Code == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// backoff returns the wait before the nth retry, n starts from 1
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d - time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// retry calls f until it succeeds, fails with an error that is not retryable
+// or the attempts are used up. The number of retries is returned.
+func (cli *Client) retry(ctx context.Context, op string, f func() error) (int, error) {
+	p := &cli.retryPolicy
+	var retries int
+	for {
+		err := f()
+		if err == nil || retries+1 >= p.MaxAttempts || ctx.Err() != nil || !p.retryable(err) {
+			return retries, err
+		}
+		retries++
+		d := p.backoff(retries)
+		log.Printf("%s: retrying in %v (%d/%d): %v", op, d, retries, p.MaxAttempts-1, err)
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return retries, err
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package atom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for _, tc := range []struct {
+		n    int
+		base time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	} {
+		// the jitter is random, sample it a few times
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(tc.n); d < tc.base/2 || d > tc.base {
+				t.Fatalf("backoff(%d) = %v, want in [%v, %v]", tc.n, d, tc.base/2, tc.base)
+			}
+		}
+	}
+}
+
+func TestBackoffUnbounded(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Millisecond}
+	if d := p.backoff(11); d < 512*time.Millisecond || d > 1024*time.Millisecond {
+		t.Fatalf("backoff(11) = %v, want in [512ms, 1024ms]", d)
+	}
+}
+
+func TestBackoffZero(t *testing.T) {
+	for _, p := range []RetryPolicy{
+		{},
+		{MaxBackoff: time.Second},
+		{InitialBackoff: -time.Second},
+	} {
+		if d := p.backoff(3); d != 0 {
+			t.Errorf("%+v: backoff(3) = %v, want 0", p, d)
+		}
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"500", &UpstreamStatusError{Code: 500}, true},
+		{"503", &UpstreamStatusError{Code: 503}, true},
+		{"429", &UpstreamStatusError{Code: 429}, true},
+		{"wrapped 502", fmt.Errorf("list: %w", &UpstreamStatusError{Code: 502}), true},
+		{"404", &UpstreamStatusError{Code: 404}, false},
+		{"400", &UpstreamStatusError{Code: 400}, false},
+		{"timeout", &net.DNSError{IsTimeout: true}, true},
+		{"unexpected eof", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"reset", &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"refused", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"not found", &net.DNSError{IsNotFound: true}, false},
+		{"canceled", context.Canceled, false},
+		{"parse", &ParseError{Err: errors.New("bad page")}, false},
+		{"session expired", ErrSessionExpired, false},
+	} {
+		if got := DefaultRetryable(tc.err); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package atom
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Session is a serializable snapshot of a logged in client, which can be
+// restored later without scanning the qr code again.
+type Session struct {
+	Id               string         `json:"id"`
+	Communities      []Community    `json:"communities"`
+	CurrentCommunity int            `json:"current_community"`
+	Cookies          []*http.Cookie `json:"cookies"`
+}
+
+// ExportSession returns a snapshot of the current session
+func (cli *Client) ExportSession() (*Session, error) {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(cli.baseUrl + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
+	return &Session{
+		Id:               cli.id,
+		Communities:      append([]Community(nil), cli.communities...),
+		CurrentCommunity: cli.curCommunity,
+		Cookies:          cli.httpclient.GetClient().Jar.Cookies(u),
+	}, nil
+}
+
+// RestoreSession logs in with a session returned by ExportSession. The
+// session is validated against the upstream site, ErrSessionExpired is
+// returned if it is no longer valid, in which case the client is left as is.
+func (cli *Client) RestoreSession(ctx context.Context, s *Session) error {
+	cli.mtx.Lock()
+	logging := cli.loginDone != nil
+	cli.mtx.Unlock()
+	if logging {
+		return ErrQRLoginAlreadyStarted
+	}
+	cli.commMtx.Lock()
+	defer cli.commMtx.Unlock()
+	u, err := url.Parse(cli.baseUrl + "/")
+	if err != nil {
+		return err
+	}
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return err
+	}
+	cookies := make([]*http.Cookie, len(s.Cookies))
+	for i, c := range s.Cookies {
+		// the jar only keeps the name and value of the exported cookies
+		cookie := *c
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+		cookies[i] = &cookie
+	}
+	jar.SetCookies(u, cookies)
+
+	// validate with a separate client so the current session survives an
+	// invalid one
+	probe := newClient(cli.history, cli.opts)
+	probe.SetTimeout(cli.httpclient.GetClient().Timeout)
+	probe.httpclient.SetCookieJar(jar)
+	name, err := probe.currentCommunityName(ctx)
+	if err != nil {
+		return err
+	}
+
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
+	cli.httpclient.SetCookieJar(jar)
+	cli.id = s.Id
+	cli.communities = append([]Community(nil), s.Communities...)
+	cli.curCommunity = cli.communityIndexByNameNoLock(name)
+	if cli.curCommunity == -1 && s.CurrentCommunity >= 0 && s.CurrentCommunity < len(cli.communities) {
+		cli.curCommunity = s.CurrentCommunity
+	}
+	cli.state.Store(kStateLoggedIn)
+	return nil
+}
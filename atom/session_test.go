@@ -0,0 +1,119 @@
+package atom_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+func TestSession(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	login(t, s, cli)
+	if err := cli.SetCurrentCommunityById("m2"); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := cli.ExportSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sessions are meant to be saved
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session atom.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newClient(s)
+	if err := restored.RestoreSession(context.Background(), &session); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.IsLoggedIn() {
+		t.Fatal("restored client is not logged in")
+	}
+	if restored.Id() != cli.Id() {
+		t.Errorf("got id %s, want %s", restored.Id(), cli.Id())
+	}
+	if got := len(restored.Communities()); got != 2 {
+		t.Errorf("got %d communities, want 2", got)
+	}
+	if got := restored.CurrentCommunity().MemberId; got != "m2" {
+		t.Errorf("current community %s, want m2", got)
+	}
+	if _, err := restored.Like(context.Background(), atom.KindNotices, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsLiked("m2", atomtest.KindNotices, "m2-1") {
+		t.Error("m2-1 is not liked")
+	}
+}
+
+func TestRestoreExpiredSession(t *testing.T) {
+	for _, inPlace := range []bool{false, true} {
+		s := newServer(t)
+		s.ServeLoginPage(inPlace)
+		cli := newClient(s)
+		login(t, s, cli)
+		session, err := cli.ExportSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.ExpireSessions()
+
+		other := newClient(s)
+		if err := other.RestoreSession(context.Background(), session); !errors.Is(err, atom.ErrSessionExpired) {
+			t.Fatalf("login page in place %v: got %v, want ErrSessionExpired", inPlace, err)
+		}
+		if other.IsLoggedIn() {
+			t.Errorf("login page in place %v: client is logged in with an expired session", inPlace)
+		}
+	}
+}
+
+func TestRestoreSessionUnknownHome(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>首页</body></html>"))
+	}))
+	t.Cleanup(srv.Close)
+
+	cli := atom.NewClient(atom.NullLikedPostsHistory{}, atom.WithBaseURL(srv.URL), atom.WithRetryPolicy(atom.NoRetry))
+	err := cli.RestoreSession(context.Background(), &atom.Session{Id: "user"})
+	if !errors.Is(err, atom.ErrParse) || errors.Is(err, atom.ErrSessionExpired) {
+		t.Fatalf("got %v, want ErrParse", err)
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	for _, inPlace := range []bool{false, true} {
+		s := newServer(t)
+		s.ServeLoginPage(inPlace)
+		cli := newClient(s)
+		qrUrl, events := startLogin(t, cli)
+		if err := s.ScanQRCode(qrUrl); err != nil {
+			t.Fatal(err)
+		}
+		if e := waitLogin(t, events); e.Kind != atom.LoggedIn {
+			t.Fatalf("login: %v %v", e.Kind, e.Err)
+		}
+		s.ExpireSessions()
+
+		if _, err := cli.Like(context.Background(), atom.KindNotices, 10); !errors.Is(err, atom.ErrSessionExpired) {
+			t.Fatalf("login page in place %v: got %v, want ErrSessionExpired", inPlace, err)
+		}
+		if cli.IsLoggedIn() {
+			t.Errorf("login page in place %v: client is logged in with an expired session", inPlace)
+		}
+		if e := waitLogin(t, events); e.Kind != atom.LoginSessionExpired {
+			t.Errorf("login page in place %v: got %v, want LoginSessionExpired", inPlace, e.Kind)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package atom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// The unlike endpoint is not confirmed against juweitong yet
+const kUnlikeApiPath = "/community/title_unlike"
+
+var ErrNoJournal = errors.New("history does not support finding liked posts")
+
+// LikedPostsJournal is a LikedPostsHistory which can find and remove the
+// recorded posts. A history must implement it to revert likes.
+type LikedPostsJournal interface {
+	LikedPostsHistory
+	// Find returns the posts of the member liked in [from, to), a zero time
+	// means no limit
+	Find(memberId string, from, to time.Time) ([]LikedPost, error)
+	Remove(post LikedPost) error
+}
+
+// Unlike cancels the like of the post with the like id in the current
+// community. It works for all kinds of posts as liking does.
+func (cli *Client) Unlike(ctx context.Context, likeId string) error {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return err
+	}
+	defer cli.holdCommunity(ctx)()
+	return cli.unlikePost(ctx, likeId)
+}
+
+// RevertLikes unlikes the posts liked by the client in [from, to) in the
+// current community, and removes them from the history. The posts found
+// already liked are kept as they may have been liked by hand. ErrNoJournal is
+// returned if the history is not a LikedPostsJournal.
+func (cli *Client) RevertLikes(ctx context.Context, from, to time.Time) (*LikeReport, error) {
+	if err := cli.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+	defer cli.holdCommunity(ctx)()
+	journal, ok := cli.history.(LikedPostsJournal)
+	if !ok {
+		return nil, ErrNoJournal
+	}
+
+	start := time.Now()
+	communityId := cli.CurrentCommunity().MemberId
+	posts, err := journal.Find(communityId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find liked posts: %w", err)
+	}
+	posts = lo.Filter(posts, func(e LikedPost, i int) bool {
+		return e.ByClient
+	})
+
+	results := make([]PostResult, len(posts))
+	wg := sync.WaitGroup{}
+	for i, p := range posts {
+		res := &results[i]
+		res.LikeId = p.PostId
+		if err := cli.acquire(ctx); err != nil {
+			res.Outcome = OutcomeFailed
+			res.Err = err
+			continue
+		}
+		wg.Add(1)
+		go func(p LikedPost) {
+			defer wg.Done()
+			defer cli.release()
+			if err := cli.unlikePost(ctx, p.PostId); err != nil {
+				res.Outcome = OutcomeFailed
+				res.Err = err
+				return
+			}
+			res.Outcome = OutcomeUnliked
+			if err := journal.Remove(p); err != nil {
+				log.Printf("failed to remove liked post: %v", err)
+			}
+		}(p)
+	}
+	wg.Wait()
+	return &LikeReport{Posts: results, Duration: time.Since(start)}, nil
+}
+
+// unlikePost unlikes the post
+func (cli *Client) unlikePost(ctx context.Context, likeId string) error {
+	if err := cli.throttle(ctx); err != nil {
+		return err
+	}
+	// not retried as an unlike which has reached the server may like the post
+	// again if sent twice
+	_, err := getWithJsonError(cli.r(ctx, OpUnlike).SetQueryParam("title", likeId), kUnlikeApiPath)
+	if err != nil {
+		return fmt.Errorf("unlike error: %w, %s", err, likeId)
+	}
+	return nil
+}
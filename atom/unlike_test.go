@@ -0,0 +1,97 @@
+package atom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+)
+
+// memJournal is a LikedPostsJournal kept in memory
+type memJournal struct {
+	memHistory
+}
+
+func (j *memJournal) Find(memberId string, from, to time.Time) ([]atom.LikedPost, error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	var posts []atom.LikedPost
+	for _, p := range j.posts {
+		if p.MemberId == memberId &&
+			(from.IsZero() || !p.LikedAt.Before(from)) && (to.IsZero() || p.LikedAt.Before(to)) {
+			posts = append(posts, p)
+		}
+	}
+	return posts, nil
+}
+
+func (j *memJournal) Remove(post atom.LikedPost) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	for i, p := range j.posts {
+		if p.MemberId == post.MemberId && p.PostId == post.PostId {
+			j.posts = append(j.posts[:i], j.posts[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestUnlike(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	login(t, s, cli)
+	if err := cli.Unlike(context.Background(), "m1-2"); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsLiked("m1", atomtest.KindNotices, "m1-2") {
+		t.Error("m1-2 is still liked")
+	}
+	// the upstream refuses to unlike a post not liked
+	if err := cli.Unlike(context.Background(), "m1-1"); err == nil {
+		t.Error("unliked a post not liked")
+	}
+}
+
+func TestRevertLikes(t *testing.T) {
+	s := newServer(t)
+	journal := &memJournal{}
+	// liked by an earlier run, out of the window
+	journal.Add(atom.LikedPost{MemberId: "m1", PostId: "m1-old", LikedAt: published, ByClient: true})
+	cli := atom.NewClient(journal, atom.WithBaseURL(s.URL()), atom.WithRetryPolicy(atom.NoRetry))
+	login(t, s, cli)
+
+	from := time.Now()
+	if _, err := cli.Like(context.Background(), atom.KindNotices, 10); err != nil {
+		t.Fatal(err)
+	}
+	report, err := cli.RevertLikes(context.Background(), from, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Posts) != 1 || report.Posts[0].LikeId != "m1-1" || report.Posts[0].Outcome != atom.OutcomeUnliked {
+		t.Fatalf("unexpected report: %v", report)
+	}
+	if s.IsLiked("m1", atomtest.KindNotices, "m1-1") {
+		t.Error("m1-1 is still liked")
+	}
+	// found liked, it may have been liked by hand
+	if !s.IsLiked("m1", atomtest.KindNotices, "m1-2") {
+		t.Error("m1-2 liked before the run is unliked")
+	}
+	if known, _ := journal.Has(atom.LikedPost{MemberId: "m1", PostId: "m1-1"}); known {
+		t.Error("the reverted post is kept in the history")
+	}
+}
+
+func TestRevertLikesWithoutJournal(t *testing.T) {
+	s := newServer(t)
+	cli := newClient(s)
+	login(t, s, cli)
+	if _, err := cli.RevertLikes(context.Background(), time.Time{}, time.Now()); !errors.Is(err, atom.ErrNoJournal) {
+		t.Fatalf("got %v, want ErrNoJournal", err)
+	}
+}
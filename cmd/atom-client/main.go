@@ -1,39 +1,285 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"time"
 
 	"github.com/alexshen/juweitong/atom"
 	"github.com/skratchdot/open-golang/open"
 )
 
-var fPost = flag.Int("post", 10, "number of posts to visit")
+var (
+	fPost        = flag.Int("post", 10, "max number of posts to visit")
+	fSince       = flag.Duration("since", 0, "only visit posts published within the duration, e.g. 168h")
+	fKnown       = flag.Int("known", 0, "stop after visiting that many consecutive posts liked before, 0 to disable")
+	fSession     = flag.String("session", "", "path to the file caching the login session")
+	fConcurrency = flag.Int("concurrency", 4, "max number of posts being liked at the same time, 0 for no limit")
+	fRPS         = flag.Float64("rps", 5, "max number of requests per second, 0 for no limit")
+	fList        = flag.Bool("list", false, "list the posts without liking them")
+	fDryRun      = flag.Bool("dry-run", false, "report the posts which would be liked without liking them")
+	fRules       = flag.String("rules", "", "path to the json file of the rules filtering posts to like")
+	fRecord      = flag.String("record", "", "path to the cassette file recording the upstream traffic, attach it to a bug report")
+	fReplay      = flag.String("replay", "", "path to the cassette file replayed in place of the upstream")
+	fSessions    = flag.Int("sessions", 1, "number of qr logins of the account, the communities are split between the sessions and liked at the same time")
+)
 
-func main() {
-	flag.Parse()
-	client := atom.NewClient(atom.NullLikedPostsHistory{})
-	loggedIn := make(chan struct{})
-	url, err := client.StartQRLogin(func() {
-		log.Print("Logged in")
-		close(loggedIn)
+// listOptions returns the options for fetching posts given by the flags
+func listOptions() atom.ListOptions {
+	opts := atom.ListOptions{
+		MaxPosts:       *fPost,
+		StopAfterKnown: *fKnown,
+	}
+	if *fSince > 0 {
+		opts.Since = time.Now().Add(-*fSince)
+	}
+	return opts
+}
+
+// listPosts logs the posts of the kind
+func listPosts(ctx context.Context, client *atom.Client, kind atom.PostKind) {
+	posts, err := client.ListPosts(ctx, kind, listOptions())
+	if err != nil {
+		log.Printf("Failed to list %s: %v", kind.Name, err)
+		return
+	}
+	log.Printf("%s: %d posts", kind.Name, len(posts))
+	for _, p := range posts {
+		liked := " "
+		if p.Liked {
+			liked = "*"
+		}
+		log.Printf("  %s %s %s [%s] likes: %d, comments: %d",
+			liked, p.PublishedAt.Format("2006-01-02 15:04"), p.Title, p.Author, p.LikeCount, p.CommentCount)
+	}
+}
+
+// likePosts likes posts of the kind and logs the report
+func likePosts(ctx context.Context, client *atom.Client, kind atom.PostKind) {
+	report, err := client.LikePaged(ctx, kind, listOptions())
+	if err != nil {
+		log.Printf("Failed to like %s: %v", kind.Name, err)
+		return
+	}
+	logReport(kind, report)
+}
+
+// logReport logs the report of liking posts of the kind
+func logReport(kind atom.PostKind, report *atom.LikeReport) {
+	log.Printf("Liked %s: %v", kind.Name, report)
+	for _, p := range report.Posts {
+		if p.Err != nil {
+			log.Printf("  %s: %v (retried %d times)", p.LikeId, p.Err, p.Retries)
+		}
+		if p.Outcome == atom.OutcomeWouldLike {
+			log.Printf("  would like %s", p.LikeId)
+		}
+	}
+}
+
+// visitCommunity lists or likes all kinds of posts in the current community
+// of the client
+func visitCommunity(ctx context.Context, client *atom.Client) {
+	log.Printf("Visiting community: %s", client.CurrentCommunity().Name)
+	for _, kind := range atom.PostKinds() {
+		if *fList {
+			listPosts(ctx, client, kind)
+		} else {
+			likePosts(ctx, client, kind)
+		}
+	}
+}
+
+// likeInParallel likes the communities with all the sessions in the pool, each
+// session likes its share of the communities at the same time as the others
+func likeInParallel(ctx context.Context, pool *atom.Pool) {
+	reports := pool.Run(ctx, atom.LikePlan{Options: listOptions()})
+	for i, report := range reports {
+		if report.Err != nil {
+			log.Printf("Session %d failed: %v", i+1, report.Err)
+			continue
+		}
+		var community atom.Community
+		for _, res := range report.Results {
+			if res.Community != community {
+				community = res.Community
+				log.Printf("Visited community: %s (session %d)", community.Name, i+1)
+			}
+			if res.Err != nil {
+				log.Printf("Failed to like %s: %v", res.Kind.Name, res.Err)
+				continue
+			}
+			logReport(res.Kind, res.Report)
+		}
+	}
+}
+
+// restoreSession logs in the client with the session saved at path
+func restoreSession(ctx context.Context, client *atom.Client, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var session atom.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return err
+	}
+	return client.RestoreSession(ctx, &session)
+}
+
+// saveSession saves the session of the client to path
+func saveSession(client *atom.Client, path string) error {
+	session, err := client.ExportSession()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// loadFilter loads the filter from the rules file at path
+func loadFilter(path string) (*atom.Filter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules atom.FilterRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return atom.NewFilter(rules)
+}
+
+// qrLogin logs in the client by scanning the qr code
+func qrLogin(ctx context.Context, client *atom.Client) {
+	done := make(chan error, 1)
+	url, err := client.StartQRLoginContext(ctx, func(e atom.LoginEvent) {
+		switch e.Kind {
+		case atom.LoginQRScanned:
+			log.Print("QR code scanned")
+		case atom.LoginQRExpired:
+			done <- errors.New("qr code expired")
+		case atom.LoginFailed:
+			done <- e.Err
+		case atom.LoggedIn:
+			log.Print("Logged in")
+			done <- nil
+		}
 	})
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Printf("QR Code: %s\n", url)
-	open.Run(url)
-	<-loggedIn
+	if *fReplay == "" {
+		open.Run(url)
+	}
+	if err := <-done; err != nil {
+		log.Fatalf("Failed to login: %v", err)
+	}
+}
+
+func main() {
+	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := []atom.Option{
+		atom.WithMaxConcurrency(*fConcurrency),
+		atom.WithRateLimit(*fRPS),
+	}
+	if *fDryRun {
+		opts = append(opts, atom.WithDryRun())
+	}
+	if *fRules != "" {
+		filter, err := loadFilter(*fRules)
+		if err != nil {
+			log.Fatalf("Failed to load rules: %v", err)
+		}
+		opts = append(opts, atom.WithFilter(filter))
+	}
+	if *fRecord != "" && *fReplay != "" {
+		log.Fatal("-record and -replay cannot be used together")
+	}
+	if *fSessions < 1 {
+		log.Fatal("-sessions must be at least 1")
+	}
+	// the traffic of several sessions cannot be told apart in a cassette
+	if *fSessions > 1 && (*fList || *fRecord != "" || *fReplay != "") {
+		log.Fatal("-sessions cannot be used with -list, -record or -replay")
+	}
+	if *fRecord != "" {
+		f, err := os.Create(*fRecord)
+		if err != nil {
+			log.Fatalf("Failed to create cassette: %v", err)
+		}
+		defer f.Close()
+		rec := atom.NewRecorder(f)
+		defer func() {
+			if err := rec.Err(); err != nil {
+				log.Printf("Failed to record: %v", err)
+			}
+		}()
+		opts = append(opts, atom.WithRecorder(rec))
+	}
+	if *fReplay != "" {
+		cassette, err := atom.LoadCassetteFile(*fReplay)
+		if err != nil {
+			log.Fatalf("Failed to load cassette: %v", err)
+		}
+		log.Printf("Replaying %s", *fReplay)
+		opts = append(opts, atom.WithReplayer(atom.NewReplayer(cassette)))
+	}
+	pool := atom.NewPool(atom.NullLikedPostsHistory{}, opts...)
+	client := pool.NewClient()
+	if *fSession != "" {
+		err := restoreSession(ctx, client, *fSession)
+		switch {
+		case err == nil:
+			log.Print("Restored session")
+		case errors.Is(err, atom.ErrSessionExpired):
+			log.Print("Session expired, please login again")
+		case !errors.Is(err, os.ErrNotExist):
+			log.Printf("Failed to restore session: %v", err)
+		}
+	}
+	if !client.IsLoggedIn() {
+		qrLogin(ctx, client)
+		// the cookies of a replayed login are redacted
+		if *fSession != "" && *fReplay == "" {
+			if err := saveSession(client, *fSession); err != nil {
+				log.Printf("Failed to save session: %v", err)
+			}
+		}
+	}
+
+	if *fSessions > 1 {
+		// the upstream keeps the current community per session, each session
+		// needs its own qr login
+		for i := 2; i <= *fSessions; i++ {
+			log.Printf("Login session %d of %d", i, *fSessions)
+			qrLogin(ctx, pool.NewClient())
+		}
+		likeInParallel(ctx, pool)
+		return
+	}
+
 	for _, comm := range client.Communities() {
+		if ctx.Err() != nil {
+			break
+		}
 		log.Printf("Switching to community: %s, %s", comm.Name, comm.MemberId)
-		if err := client.SetCurrentCommunityById(comm.MemberId); err != nil {
+		if err := client.SetCurrentCommunityByIdContext(ctx, comm.MemberId); err != nil {
 			log.Print(err)
 			continue
 		}
-		log.Printf("Visiting community: %s", comm.Name)
-		log.Printf("Liked notices: %d", client.LikeNotices(*fPost))
-		log.Printf("Liked moments: %d", client.LikeMoments(*fPost))
-		log.Printf("Liked ccp notices: %d", client.LikeCCPPosts(*fPost))
-		log.Printf("Liked proposals: %d", client.LikeProposals(*fPost))
+		visitCommunity(ctx, client)
 	}
 }
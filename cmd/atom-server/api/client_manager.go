@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -16,6 +17,29 @@ type ClientInstance struct {
 	id string
 	*atom.Client
 	t *time.Timer
+	// ctx lives as long as the instance, used for the operations that outlive
+	// the request starting them, e.g. qr login
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mtx        sync.Mutex
+	loginEvent *atom.LoginEvent // the last login event, nil if none
+}
+
+func (o *ClientInstance) setLoginEvent(e atom.LoginEvent) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.loginEvent = &e
+}
+
+// lastLoginEvent returns the last login event, false if no event is fired
+func (o *ClientInstance) lastLoginEvent() (atom.LoginEvent, bool) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if o.loginEvent == nil {
+		return atom.LoginEvent{}, false
+	}
+	return *o.loginEvent, true
 }
 
 // touch restarts the timeout timer with timeout d
@@ -37,6 +61,7 @@ type AtomClientManager struct {
 	maxAge            time.Duration
 	outRequestTimeout time.Duration
 	likedPostsDAO     dal.LikedPostsDAO
+	clientOptions     []atom.Option
 }
 
 func ClientManager() *AtomClientManager {
@@ -75,7 +100,8 @@ func (mgr *AtomClientManager) New(session *sessions.Session) (*ClientInstance, e
 		session.Values[kKeyClientId] = id
 	}
 	dao := clientLikedPostsHistory{id, mgr.likedPostsDAO}
-	inst := &ClientInstance{id: id, Client: atom.NewClient(&dao)}
+	inst := &ClientInstance{id: id, Client: atom.NewClient(&dao, mgr.clientOptions...)}
+	inst.ctx, inst.cancel = context.WithCancel(context.Background())
 	inst.Client.SetTimeout(mgr.outRequestTimeout)
 	inst.touch(mgr.maxAge, func() {
 		mgr.remove(id)
@@ -94,6 +120,7 @@ func (mgr *AtomClientManager) remove(id string) {
 
 func (mgr *AtomClientManager) removeNoLock(id string) {
 	if inst, ok := mgr.clients[id]; ok {
+		inst.cancel()
 		inst.StopQRLogin()
 		inst.stopTimer()
 		delete(mgr.clients, id)
@@ -103,15 +130,19 @@ func (mgr *AtomClientManager) removeNoLock(id string) {
 // Stop stops all qr login process
 func (mgr *AtomClientManager) Stop() {
 	for _, inst := range mgr.clients {
+		inst.cancel()
 		inst.StopQRLogin()
 	}
 }
 
 var gClientMgr *AtomClientManager
 
+// InitClientManager initializes the client manager. opts are used for creating
+// every client.
 func InitClientManager(maxAge time.Duration,
 	outRequestTimeout time.Duration,
-	likedPostsDAO dal.LikedPostsDAO) {
+	likedPostsDAO dal.LikedPostsDAO,
+	opts ...atom.Option) {
 	if gClientMgr != nil {
 		panic("InitClientManager called twice")
 	}
@@ -121,5 +152,6 @@ func InitClientManager(maxAge time.Duration,
 		maxAge:            maxAge,
 		outRequestTimeout: outRequestTimeout,
 		likedPostsDAO:     likedPostsDAO,
+		clientOptions:     opts,
 	}
 }
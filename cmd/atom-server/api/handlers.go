@@ -1,8 +1,13 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/alexshen/juweitong/atom"
 	"github.com/alexshen/juweitong/cmd/atom-server/dal"
@@ -15,14 +20,22 @@ import (
 var (
 	gStore                  sessions.Store
 	gSelectedCommunitiesDAO dal.SelectedCommunitiesDAO
+	gFilterRulesDAO         dal.FilterRulesDAO
 	gLog                    = logging.MustGetLogger("api")
 )
 
-const kSessionName = "api.session"
+const (
+	kSessionName = "api.session"
+	// the longest time window of likes reverted by a request
+	kMaxRevertWindow = 7 * 24 * time.Hour
+)
 
-func Init(store sessions.Store, selectedCommunitiesDAO dal.SelectedCommunitiesDAO) {
+func Init(store sessions.Store,
+	selectedCommunitiesDAO dal.SelectedCommunitiesDAO,
+	filterRulesDAO dal.FilterRulesDAO) {
 	gStore = store
 	gSelectedCommunitiesDAO = selectedCommunitiesDAO
+	gFilterRulesDAO = filterRulesDAO
 }
 
 func GetSession(r *http.Request) *sessions.Session {
@@ -46,16 +59,61 @@ func (o *clientLikedPostsHistory) Add(post atom.LikedPost) error {
 	return o.dao.Add(dal.LikedPost{
 		MemberId: post.MemberId,
 		PostId:   post.PostId,
+		ByClient: post.ByClient,
+	})
+}
+
+func (o *clientLikedPostsHistory) Find(memberId string, from, to time.Time) ([]atom.LikedPost, error) {
+	records, err := o.dao.FindBetween(memberId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(records, func(e dal.LikedPost, i int) atom.LikedPost {
+		return atom.LikedPost{MemberId: e.MemberId, PostId: e.PostId, LikedAt: e.CreatedAt, ByClient: e.ByClient}
+	}), nil
+}
+
+func (o *clientLikedPostsHistory) Remove(post atom.LikedPost) error {
+	return o.dao.Delete(dal.LikedPost{
+		MemberId: post.MemberId,
+		PostId:   post.PostId,
 	})
 }
 
+// communityOf returns memberId, or the member id of the current community if
+// it is empty. The community is held with WithCommunity for the whole request
+// so a request on another community cannot switch it away.
+func communityOf(client *ClientInstance, memberId string) string {
+	if memberId != "" {
+		return memberId
+	}
+	return client.CurrentCommunity().MemberId
+}
+
 func RegisterHandlers(r *mux.Router) {
 	r.HandleFunc("/api/startqrlogin", startQRLogin).Methods(http.MethodPost)
 	r.HandleFunc("/api/isloggedin", isLoggedIn).Methods(http.MethodGet)
 	r.HandleFunc("/api/getcommunities", ensureLoggedIn(getCommunities)).Methods(http.MethodGet)
 	r.HandleFunc("/api/selectcommunities", ensureLoggedIn(selectCommunities)).Methods(http.MethodPost)
 	r.HandleFunc("/api/setcurrentcommunity", ensureLoggedIn(setCurrentCommunity)).Methods(http.MethodPost)
-	r.HandleFunc("/api/like{kind:notices|moments|ccpposts|proposals}", ensureLoggedIn(likePosts)).Methods(http.MethodPost)
+	r.HandleFunc("/api/like{kind:"+postKindPattern()+"}", ensureLoggedIn(likePosts)).Methods(http.MethodPost)
+	r.HandleFunc("/api/filterrules", ensureLoggedIn(getFilterRules)).Methods(http.MethodGet)
+	r.HandleFunc("/api/filterrules", ensureLoggedIn(setFilterRules)).Methods(http.MethodPost)
+	r.HandleFunc("/api/unlike", ensureLoggedIn(unlikePost)).Methods(http.MethodPost)
+	r.HandleFunc("/api/revertlikes", ensureLoggedIn(revertLikes)).Methods(http.MethodPost)
+	r.HandleFunc("/api/posts/{kind:"+postKindPattern()+"}", ensureLoggedIn(listPosts)).Methods(http.MethodGet)
+	r.HandleFunc("/api/posts/{kind:"+postKindPattern()+"}/{id}", ensureLoggedIn(getPost)).Methods(http.MethodGet)
+	r.HandleFunc("/api/posts/{kind:"+postKindPattern()+"}/{id}/comments", ensureLoggedIn(listComments)).Methods(http.MethodGet)
+	r.HandleFunc("/api/comments", ensureLoggedIn(postComment)).Methods(http.MethodPost)
+	r.HandleFunc("/api/proposals/{id}", ensureLoggedIn(getProposal)).Methods(http.MethodGet)
+	r.HandleFunc("/api/proposals/{id}/vote", ensureLoggedIn(voteProposal)).Methods(http.MethodPost)
+}
+
+// postKindPattern returns the route pattern matching the registered post kinds
+func postKindPattern() string {
+	return strings.Join(lo.Map(atom.PostKinds(), func(e atom.PostKind, i int) string {
+		return regexp.QuoteMeta(e.Name)
+	}), "|")
 }
 
 func startQRLogin(w http.ResponseWriter, r *http.Request) {
@@ -71,8 +129,19 @@ func startQRLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	gLog.Infof("start qr login for %s", client.id)
-	qrcodeUrl, err := client.StartQRLogin(func() {
-		gLog.Infof("%s logged in", client.id)
+	// the login outlives this request, bind it to the client instance instead
+	qrcodeUrl, err := client.StartQRLoginContext(client.ctx, func(e atom.LoginEvent) {
+		client.setLoginEvent(e)
+		switch e.Kind {
+		case atom.LoginFailed:
+			gLog.Warningf("%s login failed: %v", client.id, e.Err)
+		case atom.LoggedIn:
+			gLog.Infof("%s logged in", client.id)
+		case atom.LoginSessionExpired:
+			gLog.Warningf("%s session expired", client.id)
+		default:
+			gLog.Infof("%s login %v", client.id, e.Kind)
+		}
 	})
 	if err != nil {
 		writeError(w, err)
@@ -89,6 +158,9 @@ func startQRLogin(w http.ResponseWriter, r *http.Request) {
 func isLoggedIn(w http.ResponseWriter, r *http.Request) {
 	type responseData struct {
 		LoggedIn bool `json:"loggedin"`
+		// one of scanning, scanned, expired, failed, loggedin or session_expired
+		State string `json:"state"`
+		Err   string `json:"err,omitempty"`
 	}
 
 	session, _ := gStore.Get(r, kSessionName)
@@ -98,7 +170,14 @@ func isLoggedIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeSuccess(w, responseData{client.IsLoggedIn()})
+	data := responseData{LoggedIn: client.IsLoggedIn(), State: "scanning"}
+	if e, ok := client.lastLoginEvent(); ok {
+		data.State = e.Kind.String()
+		if e.Err != nil {
+			data.Err = e.Err.Error()
+		}
+	}
+	writeSuccess(w, data)
 }
 
 type apiMustLoggedInFunc func(w http.ResponseWriter, r *http.Request, client *ClientInstance)
@@ -112,8 +191,10 @@ func ensureLoggedIn(next apiMustLoggedInFunc) http.HandlerFunc {
 			return
 		}
 
+		// the browser goes to the login page on 401, which also happens
+		// once the upstream session expires
 		if !client.IsLoggedIn() {
-			http.Error(w, "not logged in", http.StatusBadRequest)
+			http.Error(w, "not logged in", http.StatusUnauthorized)
 			return
 		}
 		next(w, r, client)
@@ -190,7 +271,7 @@ func setCurrentCommunity(w http.ResponseWriter, r *http.Request, client *ClientI
 		return
 	}
 
-	if err := client.SetCurrentCommunityById(requestData.MemberId); err != nil {
+	if err := client.SetCurrentCommunityByIdContext(r.Context(), requestData.MemberId); err != nil {
 		writeError(w, err)
 		return
 	}
@@ -198,11 +279,31 @@ func setCurrentCommunity(w http.ResponseWriter, r *http.Request, client *ClientI
 }
 
 func likePosts(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type postResult struct {
+		PostId  string `json:"post_id"`
+		Outcome string `json:"outcome"`
+		Err     string `json:"err,omitempty"`
+		Retries int    `json:"retries,omitempty"`
+	}
 	type responseData struct {
-		Count int `json:"count"`
+		Count        int          `json:"count"`
+		DryRun       bool         `json:"dry_run"`
+		AlreadyLiked int          `json:"already_liked"`
+		Skipped      int          `json:"skipped"`
+		Filtered     int          `json:"filtered"`
+		Closed       int          `json:"closed"`
+		Failed       int          `json:"failed"`
+		Duration     int64        `json:"duration_ms"`
+		Posts        []postResult `json:"posts"`
 	}
 
-	type requestData responseData
+	type requestData struct {
+		Count          int       `json:"count"`
+		Since          time.Time `json:"since"`
+		StopAfterKnown int       `json:"stop_after_known"`
+		// the community to like, the current one if not set
+		MemberId string `json:"member_id"`
+	}
 
 	var query requestData
 	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
@@ -217,22 +318,408 @@ func likePosts(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
 		return
 	}
 
-	var numPosts int
-	kind, _ := mux.Vars(r)["kind"]
-	switch kind {
-	case "notices":
-		numPosts = client.LikeNotices(query.Count)
-	case "moments":
-		numPosts = client.LikeMoments(query.Count)
-	case "ccpposts":
-		numPosts = client.LikeCCPPosts(query.Count)
-	case "proposals":
-		numPosts = client.LikeProposals(query.Count)
-	default:
-		gLog.Errorf("unhandled like kind: %s", kind)
+	opts := atom.ListOptions{
+		MaxPosts:       query.Count,
+		Since:          query.Since,
+		StopAfterKnown: query.StopAfterKnown,
+	}
+	kind, ok := atom.LookupPostKind(mux.Vars(r)["kind"])
+	if !ok {
+		gLog.Errorf("unhandled like kind: %s", mux.Vars(r)["kind"])
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	filter, err := loadFilter(client.Id())
+	if err != nil {
+		gLog.Errorf("failed to load filter rules: %v", err)
+		writeError(w, err)
+		return
+	}
+	client.SetFilter(filter)
+
+	var report *atom.LikeReport
+	err = client.WithCommunity(r.Context(), communityOf(client, query.MemberId), func(ctx context.Context) (err error) {
+		report, err = client.LikePaged(ctx, kind, opts)
+		return err
+	})
+	if err != nil {
+		gLog.Errorf("failed to like %s: %v", kind.Name, err)
+		writeError(w, err)
+		return
+	}
+
+	for _, e := range report.Errors() {
+		gLog.Warningf("failed to like %s: %v", kind.Name, e)
+	}
+	if !client.IsLoggedIn() {
+		// the session expired while liking, the rest of the posts failed
+		writeError(w, atom.ErrSessionExpired)
+		return
+	}
+	writeSuccess(w, responseData{
+		Count:        report.Count(atom.OutcomeLiked) + report.Count(atom.OutcomeWouldLike),
+		DryRun:       client.DryRun(),
+		AlreadyLiked: report.Count(atom.OutcomeAlreadyLiked),
+		Skipped:      report.Count(atom.OutcomeSkipped),
+		Filtered:     report.Count(atom.OutcomeFiltered),
+		Closed:       report.Count(atom.OutcomeClosed),
+		Failed:       report.Count(atom.OutcomeFailed),
+		Duration:     report.Duration.Milliseconds(),
+		Posts: lo.Map(report.Posts, func(e atom.PostResult, i int) postResult {
+			res := postResult{PostId: e.LikeId, Outcome: e.Outcome.String(), Retries: e.Retries}
+			if e.Err != nil {
+				res.Err = e.Err.Error()
+			}
+			return res
+		}),
+	})
+}
+
+// loadFilter returns the filter of the user, nil if the user has no rules
+func loadFilter(userId string) (*atom.Filter, error) {
+	data, err := gFilterRulesDAO.Find(userId)
+	if err != nil || data == "" {
+		return nil, err
+	}
+	var rules atom.FilterRules
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, err
+	}
+	return atom.NewFilter(rules)
+}
+
+func getFilterRules(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	data, err := gFilterRulesDAO.Find(client.Id())
+	if err != nil {
+		gLog.Errorf("failed to find filter rules: %v", err)
+		writeError(w, err)
+		return
+	}
+	var rules atom.FilterRules
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &rules); err != nil {
+			gLog.Errorf("invalid filter rules of %s: %v", client.Id(), err)
+			writeError(w, err)
+			return
+		}
+	}
+	writeSuccess(w, rules)
+}
+
+func setFilterRules(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	var rules atom.FilterRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		gLog.Errorf("invalid query: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := atom.NewFilter(rules); err != nil {
+		gLog.Errorf("invalid filter rules: %v", err)
+		writeErrorStatus(w, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := gFilterRulesDAO.Save(dal.FilterRules{UserId: client.Id(), Rules: string(data)}); err != nil {
+		gLog.Errorf("failed to save filter rules: %v", err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, nil)
+}
+
+func unlikePost(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	var requestData = struct {
+		PostId string `json:"post_id"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil || requestData.PostId == "" {
+		gLog.Errorf("invalid query: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := client.Unlike(r.Context(), requestData.PostId); err != nil {
+		gLog.Errorf("failed to unlike %s: %v", requestData.PostId, err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, nil)
+}
+
+func revertLikes(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type postResult struct {
+		PostId  string `json:"post_id"`
+		Outcome string `json:"outcome"`
+		Err     string `json:"err,omitempty"`
+	}
+	type responseData struct {
+		Unliked  int          `json:"unliked"`
+		Failed   int          `json:"failed"`
+		Duration int64        `json:"duration_ms"`
+		Posts    []postResult `json:"posts"`
+	}
+
+	type requestData struct {
+		MemberId string    `json:"member_id"`
+		From     time.Time `json:"from"`
+		To       time.Time `json:"to"`
+	}
+
+	var query requestData
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		gLog.Errorf("invalid query: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// never revert all the likes by an empty request
+	if query.From.IsZero() || query.To.IsZero() || !query.From.Before(query.To) ||
+		query.To.Sub(query.From) > kMaxRevertWindow {
+		gLog.Errorf("invalid time window [%v, %v)", query.From, query.To)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var report *atom.LikeReport
+	err := client.WithCommunity(r.Context(), communityOf(client, query.MemberId), func(ctx context.Context) (err error) {
+		report, err = client.RevertLikes(ctx, query.From, query.To)
+		return err
+	})
+	if err != nil {
+		gLog.Errorf("failed to revert likes: %v", err)
+		writeError(w, err)
+		return
+	}
+
+	for _, e := range report.Errors() {
+		gLog.Warningf("failed to unlike: %v", e)
+	}
+	writeSuccess(w, responseData{
+		Unliked:  report.Count(atom.OutcomeUnliked),
+		Failed:   report.Count(atom.OutcomeFailed),
+		Duration: report.Duration.Milliseconds(),
+		Posts: lo.Map(report.Posts, func(e atom.PostResult, i int) postResult {
+			res := postResult{PostId: e.LikeId, Outcome: e.Outcome.String()}
+			if e.Err != nil {
+				res.Err = e.Err.Error()
+			}
+			return res
+		}),
+	})
+}
+
+func listPosts(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type post struct {
+		ViewId       string    `json:"view_id"`
+		LikeId       string    `json:"like_id"`
+		Title        string    `json:"title"`
+		Author       string    `json:"author"`
+		PublishedAt  time.Time `json:"published_at"`
+		LikeCount    int       `json:"like_count"`
+		CommentCount int       `json:"comment_count"`
+		Liked        bool      `json:"liked"`
+	}
+	type responseData struct {
+		Posts []post `json:"posts"`
+	}
+
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count <= 0 {
+		gLog.Errorf("invalid count %q", r.URL.Query().Get("count"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	kind, ok := atom.LookupPostKind(mux.Vars(r)["kind"])
+	if !ok {
+		gLog.Errorf("unhandled post kind: %s", mux.Vars(r)["kind"])
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	posts, err := client.ListPosts(r.Context(), kind, atom.ListOptions{MaxPosts: count})
+	if err != nil {
+		gLog.Errorf("failed to list %s: %v", kind.Name, err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, responseData{
+		Posts: lo.Map(posts, func(e atom.Post, i int) post {
+			return post(e)
+		}),
+	})
+}
+
+func getPost(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type attachment struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+	type responseData struct {
+		ViewId      string       `json:"view_id"`
+		Title       string       `json:"title"`
+		Publisher   string       `json:"publisher"`
+		PublishedAt time.Time    `json:"published_at"`
+		Text        string       `json:"text"`
+		HTML        string       `json:"html"`
+		Images      []string     `json:"images"`
+		Attachments []attachment `json:"attachments"`
+		Liked       bool         `json:"liked"`
+	}
+
+	kind, ok := atom.LookupPostKind(mux.Vars(r)["kind"])
+	if !ok {
+		gLog.Errorf("unhandled post kind: %s", mux.Vars(r)["kind"])
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	post, err := client.GetPost(r.Context(), kind, mux.Vars(r)["id"])
+	if err != nil {
+		gLog.Errorf("failed to get %s %s: %v", kind.Name, mux.Vars(r)["id"], err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, responseData{
+		ViewId:      post.ViewId,
+		Title:       post.Title,
+		Publisher:   post.Publisher,
+		PublishedAt: post.PublishedAt,
+		Text:        post.Text,
+		HTML:        post.HTML,
+		Images:      post.Images,
+		Attachments: lo.Map(post.Attachments, func(e atom.Attachment, i int) attachment {
+			return attachment(e)
+		}),
+		Liked: post.Liked,
+	})
+}
+
+func getProposal(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type choice struct {
+		Id     string `json:"id"`
+		Text   string `json:"text"`
+		Votes  int    `json:"votes"`
+		Chosen bool   `json:"chosen"`
+	}
+	type responseData struct {
+		ViewId   string    `json:"view_id"`
+		CaseId   string    `json:"case_id"`
+		Title    string    `json:"title"`
+		Text     string    `json:"text"`
+		Status   string    `json:"status"`
+		Deadline time.Time `json:"deadline"`
+		Choices  []choice  `json:"choices"`
+	}
+
+	p, err := client.GetProposal(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		gLog.Errorf("failed to get proposal %s: %v", mux.Vars(r)["id"], err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, responseData{
+		ViewId:   p.ViewId,
+		CaseId:   p.CaseId,
+		Title:    p.Title,
+		Text:     p.Text,
+		Status:   p.Status.String(),
+		Deadline: p.Deadline,
+		Choices: lo.Map(p.Choices, func(e atom.VoteChoice, i int) choice {
+			return choice(e)
+		}),
+	})
+}
+
+func voteProposal(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type requestData struct {
+		Choice  string `json:"choice"`
+		Abstain bool   `json:"abstain"`
+	}
+
+	var query requestData
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		gLog.Errorf("invalid query: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if query.Choice != "" && query.Abstain || query.Choice == "" && !query.Abstain {
+		gLog.Errorf("either choice or abstain must be given")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p, err := client.GetProposal(r.Context(), mux.Vars(r)["id"])
+	if err == nil {
+		if query.Abstain {
+			err = client.Abstain(r.Context(), p)
+		} else {
+			err = client.Vote(r.Context(), p, query.Choice)
+		}
+	}
+	if err != nil {
+		gLog.Errorf("failed to vote proposal %s: %v", mux.Vars(r)["id"], err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, nil)
+}
+
+func listComments(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type comment struct {
+		Id          string    `json:"id"`
+		Author      string    `json:"author"`
+		Text        string    `json:"text"`
+		PublishedAt time.Time `json:"published_at"`
+		ReplyTo     string    `json:"reply_to,omitempty"`
+	}
+	type responseData struct {
+		Comments []comment `json:"comments"`
+	}
+
+	kind, ok := atom.LookupPostKind(mux.Vars(r)["kind"])
+	if !ok {
+		gLog.Errorf("unhandled post kind: %s", mux.Vars(r)["kind"])
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	comments, err := client.ListComments(r.Context(), kind, mux.Vars(r)["id"])
+	if err != nil {
+		gLog.Errorf("failed to list comments of %s %s: %v", kind.Name, mux.Vars(r)["id"], err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, responseData{
+		Comments: lo.Map(comments, func(e atom.Comment, i int) comment {
+			return comment(e)
+		}),
+	})
+}
+
+func postComment(w http.ResponseWriter, r *http.Request, client *ClientInstance) {
+	type requestData struct {
+		PostId  string `json:"post_id"`
+		Text    string `json:"text"`
+		ReplyTo string `json:"reply_to"`
+	}
+
+	var query requestData
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		gLog.Errorf("invalid query: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if query.PostId == "" {
+		gLog.Errorf("empty post id")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	writeSuccess(w, responseData{numPosts})
+	if err := client.PostComment(r.Context(), query.PostId, query.Text, query.ReplyTo); err != nil {
+		gLog.Errorf("failed to comment on %s: %v", query.PostId, err)
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, nil)
 }
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alexshen/juweitong/atom"
+	"github.com/alexshen/juweitong/atom/atomtest"
+	"github.com/alexshen/juweitong/cmd/atom-server/dal"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+var (
+	gUpstream *atomtest.Server
+	gServer   *httptest.Server
+)
+
+func TestMain(m *testing.M) {
+	gUpstream = atomtest.NewServer()
+	gUpstream.AddCommunity(atomtest.Community{Name: "东区", MemberId: "m1"})
+	gUpstream.AddCommunity(atomtest.Community{Name: "西区", MemberId: "m2"})
+
+	Init(sessions.NewCookieStore(securecookie.GenerateRandomKey(32)),
+		dal.NullSelectedCommunitiesDAO{},
+		dal.NullFilterRulesDAO{})
+	InitClientManager(time.Minute, 10*time.Second, dal.NullLikedPostsDAO{},
+		atom.WithBaseURL(gUpstream.URL()),
+		atom.WithRetryPolicy(atom.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	router := mux.NewRouter()
+	RegisterHandlers(router)
+	gServer = httptest.NewServer(router)
+
+	code := m.Run()
+	ClientManager().Stop()
+	gServer.Close()
+	gUpstream.Close()
+	os.Exit(code)
+}
+
+// newBrowser returns a client keeping the session cookie like a browser
+func newBrowser(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar}
+}
+
+// call sends the request to the api and decodes the data of a successful
+// response into data. The status code is returned.
+func call(t *testing.T, c *http.Client, method string, path string, request any, data any) int {
+	t.Helper()
+	var body io.Reader
+	if request != nil {
+		b, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, gServer.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && data != nil {
+		msg := responseMessage{Data: data}
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Success {
+			t.Fatalf("%s %s: %s", method, path, msg.Err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// login logs in through the api by scanning the qr code on the upstream
+func login(t *testing.T, c *http.Client) {
+	t.Helper()
+	var qrcode struct {
+		Url string `json:"url"`
+	}
+	if code := call(t, c, http.MethodPost, "/api/startqrlogin", nil, &qrcode); code != http.StatusOK {
+		t.Fatalf("startqrlogin: got status %d", code)
+	}
+	if err := gUpstream.ScanQRCode(qrcode.Url); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		var state struct {
+			LoggedIn bool   `json:"loggedin"`
+			State    string `json:"state"`
+			Err      string `json:"err"`
+		}
+		if code := call(t, c, http.MethodGet, "/api/isloggedin", nil, &state); code != http.StatusOK {
+			t.Fatalf("isloggedin: got status %d", code)
+		}
+		if state.LoggedIn {
+			return
+		}
+		if state.Err != "" {
+			t.Fatalf("login %s: %s", state.State, state.Err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("login timed out")
+}
+
+// addPost publishes a new notice in the communities and returns its like id.
+// The upstream is shared by the runs of the tests, which publish new posts to
+// like.
+func addPost(members ...string) string {
+	likeId := strconv.FormatInt(time.Now().UnixNano(), 10)
+	for _, member := range members {
+		gUpstream.AddPost(member, atomtest.KindNotices, atomtest.Post{
+			ViewId: "v" + likeId,
+			LikeId: likeId,
+			Title:  "停水通知",
+			Time:   time.Now(),
+		})
+	}
+	return likeId
+}
+
+func TestRequireLogin(t *testing.T) {
+	c := newBrowser(t)
+	for _, path := range []string{"/api/isloggedin", "/api/getcommunities"} {
+		if code := call(t, c, http.MethodGet, path, nil, nil); code != http.StatusUnauthorized {
+			t.Errorf("%s: got status %d, want 401", path, code)
+		}
+	}
+}
+
+func TestLikePosts(t *testing.T) {
+	likeId := addPost("m1", "m2")
+
+	c := newBrowser(t)
+	login(t, c)
+
+	var communities struct {
+		Communities []community `json:"communities"`
+		Current     string      `json:"current"`
+	}
+	if code := call(t, c, http.MethodGet, "/api/getcommunities", nil, &communities); code != http.StatusOK {
+		t.Fatalf("getcommunities: got status %d", code)
+	}
+	if len(communities.Communities) != 2 || communities.Current != "m1" {
+		t.Fatalf("unexpected communities: %+v", communities)
+	}
+
+	type likeRequest struct {
+		Count    int    `json:"count"`
+		MemberId string `json:"member_id,omitempty"`
+	}
+	if code := call(t, c, http.MethodPost, "/api/likenotices", likeRequest{}, nil); code != http.StatusBadRequest {
+		t.Errorf("like with no count: got status %d, want 400", code)
+	}
+	if code := call(t, c, http.MethodPost, "/api/likenotices",
+		likeRequest{Count: 10, MemberId: "unknown"}, nil); code != http.StatusBadRequest {
+		t.Errorf("like in an unknown community: got status %d, want 400", code)
+	}
+
+	var liked struct {
+		Count int `json:"count"`
+	}
+	if code := call(t, c, http.MethodPost, "/api/likenotices",
+		likeRequest{Count: 1, MemberId: "m2"}, &liked); code != http.StatusOK {
+		t.Fatalf("likenotices: got status %d", code)
+	}
+	if liked.Count != 1 {
+		t.Errorf("got %d liked, want 1", liked.Count)
+	}
+	if !gUpstream.IsLiked("m2", atomtest.KindNotices, likeId) {
+		t.Error("the post is not liked")
+	}
+	if gUpstream.IsLiked("m1", atomtest.KindNotices, likeId) {
+		t.Error("the post of the current community is liked")
+	}
+}
+
+func TestLikeRetries(t *testing.T) {
+	likeId := addPost("m1")
+	c := newBrowser(t)
+	login(t, c)
+
+	gUpstream.FailNext("/community/title_like", 1, http.StatusServiceUnavailable)
+	var liked struct {
+		Posts []struct {
+			PostId  string `json:"post_id"`
+			Outcome string `json:"outcome"`
+			Retries int    `json:"retries"`
+		} `json:"posts"`
+	}
+	if code := call(t, c, http.MethodPost, "/api/likenotices", map[string]int{"count": 1}, &liked); code != http.StatusOK {
+		t.Fatalf("likenotices: got status %d", code)
+	}
+	if len(liked.Posts) != 1 || liked.Posts[0].PostId != likeId || liked.Posts[0].Retries != 1 {
+		t.Fatalf("unexpected posts: %+v", liked.Posts)
+	}
+	if !gUpstream.IsLiked("m1", atomtest.KindNotices, likeId) {
+		t.Error("the post is not liked")
+	}
+}
+
+func TestComments(t *testing.T) {
+	likeId := addPost("m1")
+	c := newBrowser(t)
+	login(t, c)
+
+	type commentRequest struct {
+		PostId  string `json:"post_id"`
+		Text    string `json:"text"`
+		ReplyTo string `json:"reply_to,omitempty"`
+	}
+	if code := call(t, c, http.MethodPost, "/api/comments", commentRequest{Text: "收到"}, nil); code != http.StatusBadRequest {
+		t.Errorf("comment without a post: got status %d, want 400", code)
+	}
+	if code := call(t, c, http.MethodPost, "/api/comments",
+		commentRequest{PostId: likeId, Text: "收到"}, nil); code != http.StatusOK {
+		t.Fatalf("comments: got status %d", code)
+	}
+
+	var thread struct {
+		Comments []struct {
+			Id   string `json:"id"`
+			Text string `json:"text"`
+		} `json:"comments"`
+	}
+	if code := call(t, c, http.MethodGet, "/api/posts/notices/v"+likeId+"/comments", nil, &thread); code != http.StatusOK {
+		t.Fatalf("list comments: got status %d", code)
+	}
+	if len(thread.Comments) != 1 || thread.Comments[0].Text != "收到" {
+		t.Fatalf("unexpected comments: %+v", thread.Comments)
+	}
+}
+
+func TestUnlike(t *testing.T) {
+	likeId := addPost("m1")
+	c := newBrowser(t)
+	login(t, c)
+
+	if code := call(t, c, http.MethodPost, "/api/likenotices", map[string]int{"count": 1}, nil); code != http.StatusOK {
+		t.Fatalf("likenotices: got status %d", code)
+	}
+	if code := call(t, c, http.MethodPost, "/api/unlike", map[string]string{"post_id": likeId}, nil); code != http.StatusOK {
+		t.Fatalf("unlike: got status %d", code)
+	}
+	if gUpstream.IsLiked("m1", atomtest.KindNotices, likeId) {
+		t.Error("the post is still liked")
+	}
+
+	// a window is required so a request never reverts all the likes
+	now := time.Now()
+	for _, window := range []map[string]time.Time{
+		{},
+		{"from": now},
+		{"from": now, "to": now.Add(-time.Hour)},
+		{"from": now.Add(-30 * 24 * time.Hour), "to": now},
+	} {
+		if code := call(t, c, http.MethodPost, "/api/revertlikes", window, nil); code != http.StatusBadRequest {
+			t.Errorf("revert %v: got status %d, want 400", window, code)
+		}
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	addPost("m1")
+	c := newBrowser(t)
+	login(t, c)
+
+	gUpstream.ExpireSessions()
+	if code := call(t, c, http.MethodPost, "/api/likenotices", map[string]int{"count": 1}, nil); code != http.StatusUnauthorized {
+		t.Errorf("likenotices: got status %d, want 401", code)
+	}
+	if code := call(t, c, http.MethodGet, "/api/getcommunities", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("getcommunities: got status %d, want 401", code)
+	}
+}
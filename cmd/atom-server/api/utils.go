@@ -1,8 +1,12 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/alexshen/juweitong/atom"
 )
 
 type responseMessage struct {
@@ -12,7 +16,12 @@ type responseMessage struct {
 }
 
 func writeJSON(w http.ResponseWriter, obj any) {
+	writeJSONStatus(w, http.StatusOK, obj)
+}
+
+func writeJSONStatus(w http.ResponseWriter, code int, obj any) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 	if err := json.NewEncoder(w).Encode(obj); err != nil {
 		gLog.Error(err)
 	}
@@ -25,9 +34,40 @@ func writeSuccess(w http.ResponseWriter, data any) {
 	})
 }
 
+// writeError writes the error with the status code mapped from the error
 func writeError(w http.ResponseWriter, err error) {
-	writeJSON(w, responseMessage{
+	writeErrorStatus(w, errorStatus(err), err)
+}
+
+func writeErrorStatus(w http.ResponseWriter, code int, err error) {
+	writeJSONStatus(w, code, responseMessage{
 		Success: false,
 		Err:     err.Error(),
 	})
 }
+
+// errorStatus maps the error to the status code of the response
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, atom.ErrNotLoggedIn), errors.Is(err, atom.ErrSessionExpired):
+		return http.StatusUnauthorized
+	case errors.Is(err, atom.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, atom.ErrUpstreamStatus), errors.Is(err, atom.ErrParse):
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, atom.ErrUnknownCommunity),
+		errors.Is(err, atom.ErrInvalidChoice),
+		errors.Is(err, atom.ErrNoAbstainChoice),
+		errors.Is(err, atom.ErrEmptyComment):
+		return http.StatusBadRequest
+	case errors.Is(err, atom.ErrProposalClosed),
+		errors.Is(err, atom.ErrCommunityHeld),
+		errors.Is(err, atom.ErrQRLoginAlreadyStarted):
+		return http.StatusConflict
+	case errors.Is(err, atom.ErrNoJournal):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
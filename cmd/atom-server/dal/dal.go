@@ -6,11 +6,16 @@ type LikedPost struct {
 	MemberId  string `gorm:"primaryKey"`
 	PostId    string `gorm:"primaryKey"`
 	CreatedAt time.Time
+	ByClient  bool // false for posts found liked already, and for old records
 }
 
 type LikedPostsDAO interface {
 	Has(record LikedPost) (bool, error)
 	Add(record LikedPost) error
+	// FindBetween returns the posts of the member created in [from, to), a
+	// zero time means no limit
+	FindBetween(memberId string, from, to time.Time) ([]LikedPost, error)
+	Delete(record LikedPost) error
 }
 
 type SelectedCommunity struct {
@@ -18,6 +23,18 @@ type SelectedCommunity struct {
 	MemberId string `gorm:"primaryKey"`
 }
 
+type FilterRules struct {
+	UserId string `gorm:"primaryKey"`
+	Rules  string // atom.FilterRules in json
+}
+
+type FilterRulesDAO interface {
+	// Find returns the rules of the user, empty if not set
+	Find(userId string) (string, error)
+	// Save inserts or replaces the rules of the user
+	Save(record FilterRules) error
+}
+
 type SelectedCommunitiesDAO interface {
 	// FindAll returns all the selected member ids
 	FindAll(userId string) ([]string, error)
@@ -1,6 +1,8 @@
 package dal
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -33,6 +35,26 @@ func (o *dbLikedPostsDAO) Add(record LikedPost) error {
 	return o.db.Create(&record).Error
 }
 
+func (o *dbLikedPostsDAO) FindBetween(memberId string, from, to time.Time) ([]LikedPost, error) {
+	// created_at is stored as text in local time, convert to compare correctly
+	query := o.db.Where("member_id = ?", memberId)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from.Local())
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to.Local())
+	}
+	var results []LikedPost
+	if err := query.Order("created_at").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (o *dbLikedPostsDAO) Delete(record LikedPost) error {
+	return o.db.Delete(&record).Error
+}
+
 type dbSelectedCommunitiesDAO struct {
 	db *gorm.DB
 }
@@ -63,3 +85,24 @@ func (o *dbSelectedCommunitiesDAO) Add(record SelectedCommunity) (bool, error) {
 func (o *dbSelectedCommunitiesDAO) Delete(record SelectedCommunity) error {
 	return o.db.Delete(record).Error
 }
+
+type dbFilterRulesDAO struct {
+	db *gorm.DB
+}
+
+func NewFilterRulesDAO(db *gorm.DB) FilterRulesDAO {
+	return &dbFilterRulesDAO{db}
+}
+
+func (o *dbFilterRulesDAO) Find(userId string) (string, error) {
+	var record FilterRules
+	err := o.db.Where("user_id = ?", userId).Limit(1).Find(&record).Error
+	if err != nil {
+		return "", err
+	}
+	return record.Rules, nil
+}
+
+func (o *dbFilterRulesDAO) Save(record FilterRules) error {
+	return o.db.Save(&record).Error
+}
@@ -1,5 +1,7 @@
 package dal
 
+import "time"
+
 type NullLikedPostsDAO struct{}
 
 func (o NullLikedPostsDAO) Has(record LikedPost) (bool, error) {
@@ -10,6 +12,14 @@ func (o NullLikedPostsDAO) Add(record LikedPost) error {
 	return nil
 }
 
+func (o NullLikedPostsDAO) FindBetween(memberId string, from, to time.Time) ([]LikedPost, error) {
+	return nil, nil
+}
+
+func (o NullLikedPostsDAO) Delete(record LikedPost) error {
+	return nil
+}
+
 type NullSelectedCommunitiesDAO struct{}
 
 func (o NullSelectedCommunitiesDAO) FindAll(userId string) ([]string, error) {
@@ -23,3 +33,13 @@ func (o NullSelectedCommunitiesDAO) Add(s SelectedCommunity) (bool, error) {
 func (o NullSelectedCommunitiesDAO) Delete(s SelectedCommunity) error {
 	return nil
 }
+
+type NullFilterRulesDAO struct{}
+
+func (o NullFilterRulesDAO) Find(userId string) (string, error) {
+	return "", nil
+}
+
+func (o NullFilterRulesDAO) Save(record FilterRules) error {
+	return nil
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
+	"context"
 	"io"
 	"os"
 
+	"github.com/alexshen/juweitong/atom"
 	"github.com/alexshen/juweitong/cmd/atom-server/ioutil"
 	"github.com/op/go-logging"
 )
 
 var (
-	gLogWriter *ioutil.RedirectableWriter
+	gLogWriter   *ioutil.RedirectableWriter
+	gUpstreamLog = logging.MustGetLogger("upstream")
 )
 
 func initLogging(w io.Writer, level logging.Level) {
@@ -30,3 +33,22 @@ func setLogWriter(w io.Writer) {
 func uninitLogging() {
 	gLogWriter = nil
 }
+
+// logUpstreamResponse logs the upstream requests of the clients at the debug
+// level
+func logUpstreamResponse(ctx context.Context, info atom.ResponseInfo) {
+	if !gUpstreamLog.IsEnabledFor(logging.DEBUG) {
+		return
+	}
+	community := info.Community.Name
+	if community == "" {
+		community = "-"
+	}
+	if info.Err != nil {
+		gUpstreamLog.Debugf("%s %s %s [%s] failed in %v: %v",
+			info.Op, info.Method, info.URL, community, info.Latency, info.Err)
+		return
+	}
+	gUpstreamLog.Debugf("%s %s %s [%s] %d in %v",
+		info.Op, info.Method, info.URL, community, info.StatusCode, info.Latency)
+}
@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alexshen/juweitong/atom"
 	"github.com/alexshen/juweitong/cmd/atom-server/api"
 	"github.com/alexshen/juweitong/cmd/atom-server/dal"
 	"github.com/alexshen/juweitong/cmd/atom-server/ioutil"
@@ -55,6 +56,10 @@ var (
 	fHtmlPath          = flag.String("html", "", "root path to the html templates")
 	fShutdownTimeout   = flag.Int("shutdown", 60, "graceful shutdown timeout in seconds")
 	fDBPath            = flag.String("db", "", "path to the sqlite3 database")
+	fConcurrency       = flag.Int("concurrency", 4, "max number of posts being liked at the same time by a client, 0 for no limit")
+	fRPS               = flag.Float64("rps", 10, "max number of outgoing requests per second shared by all clients, 0 for no limit")
+	fDryRun            = flag.Bool("dry-run", false, "report the posts which would be liked without liking them")
+	fMetricsAddr       = flag.String("metrics", "", "address serving the upstream metrics at /debug/vars, e.g. localhost:9090, disabled if empty")
 	fLogLevel          loggingLevel
 )
 
@@ -123,28 +128,48 @@ func main() {
 
 	var likedPostsDAO dal.LikedPostsDAO
 	var selectedCommunitiesDAO dal.SelectedCommunitiesDAO
+	var filterRulesDAO dal.FilterRulesDAO
 	if *fDBPath != "" {
 		gLog.Infof("using db at path %s", *fDBPath)
 		db, err := gorm.Open(sqlite.Open(*fDBPath), &gorm.Config{})
 		if err != nil {
 			gLog.Fatal(err)
 		}
-		if err := db.AutoMigrate(&dal.LikedPost{}, &dal.SelectedCommunity{}); err != nil {
+		if err := db.AutoMigrate(&dal.LikedPost{}, &dal.SelectedCommunity{}, &dal.FilterRules{}); err != nil {
 			gLog.Fatal(err)
 		}
 		likedPostsDAO = dal.NewDBLikedPostsDAO(db)
 		selectedCommunitiesDAO = dal.NewSelectedCommunitiesDAO(db)
+		filterRulesDAO = dal.NewFilterRulesDAO(db)
 	} else {
 		gLog.Info("running without using db")
 		likedPostsDAO = dal.NullLikedPostsDAO{}
 		selectedCommunitiesDAO = dal.NullSelectedCommunitiesDAO{}
+		filterRulesDAO = dal.NullFilterRulesDAO{}
+	}
+
+	clientOpts := []atom.Option{
+		atom.WithMaxConcurrency(*fConcurrency),
+		atom.WithObserver(atom.ObserverFuncs{After: logUpstreamResponse}),
+		atom.WithObserver(atom.ObserverFuncs{After: recordUpstreamResponse}),
+	}
+	if *fMetricsAddr != "" {
+		go serveMetrics(*fMetricsAddr)
+	}
+	if *fRPS > 0 {
+		clientOpts = append(clientOpts, atom.WithRateLimiter(atom.NewRateLimiter(*fRPS)))
+	}
+	if *fDryRun {
+		gLog.Info("running in dry run mode")
+		clientOpts = append(clientOpts, atom.WithDryRun())
 	}
 
 	router := mux.NewRouter()
-	api.Init(store, selectedCommunitiesDAO)
+	api.Init(store, selectedCommunitiesDAO, filterRulesDAO)
 	api.InitClientManager(time.Second*time.Duration(*fMaxAge),
 		time.Second*time.Duration(*fOutRequestTimeout),
-		likedPostsDAO)
+		likedPostsDAO,
+		clientOpts...)
 	api.RegisterHandlers(router)
 
 	// register assets handlers
@@ -158,7 +183,7 @@ func main() {
 	if *fHtmlPath == "" {
 		gLog.Fatal("html root path not specified")
 	}
-	web.Init(*fHtmlPath, selectedCommunitiesDAO)
+	web.Init(*fHtmlPath, *fAssetPath, selectedCommunitiesDAO)
 	web.RegisterHandlers(router)
 
 	server := http.Server{
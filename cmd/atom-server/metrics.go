@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"strconv"
+
+	"github.com/alexshen/juweitong/atom"
+)
+
+// the upstream requests of all clients, keyed by <op>.<name>
+var gUpstreamMetrics = expvar.NewMap("upstream")
+
+// recordUpstreamResponse counts the upstream requests by the operation and the
+// outcome, and sums up their latency
+func recordUpstreamResponse(ctx context.Context, info atom.ResponseInfo) {
+	op := string(info.Op)
+	if op == "" {
+		op = "other"
+	}
+	gUpstreamMetrics.Add(op+".requests", 1)
+	gUpstreamMetrics.Add(op+".latency_ms", info.Latency.Milliseconds())
+	if info.Err != nil {
+		gUpstreamMetrics.Add(op+".errors", 1)
+	}
+	if info.StatusCode != 0 {
+		gUpstreamMetrics.Add(op+".status_"+strconv.Itoa(info.StatusCode/100)+"xx", 1)
+	}
+}
+
+// serveMetrics serves the metrics in json at /debug/vars on addr
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	gLog.Infof("serving metrics at %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		gLog.Errorf("failed to serve metrics: %v", err)
+	}
+}
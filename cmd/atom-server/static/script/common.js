@@ -24,7 +24,10 @@
                         }});
                         return Promise.reject(IGNORE_ERROR);
                     }
-                    return Promise.reject(`请求错误, ${response.statusText}`);
+                    // errors of the api carry the message in the body
+                    return response.json()
+                        .catch(() => ({}))
+                        .then((result) => Promise.reject(`请求错误, ${result.err || response.statusText}`));
                 }
                 return response.json();
             })
@@ -3,6 +3,7 @@ package web
 import (
 	"io/ioutil"
 	"net/http"
+	"os"
 	"path/filepath"
 	"text/template"
 
@@ -14,14 +15,18 @@ import (
 	"github.com/samber/lo"
 )
 
+const kDefaultKindIcon = "post.png"
+
 var (
 	gHtmlRoot               string
+	gAssetRoot              string
 	gSelectedCommunitiesDAO dal.SelectedCommunitiesDAO
 	gLog                    = logging.MustGetLogger("web")
 )
 
-func Init(root string, selectedCommunitiesDAO dal.SelectedCommunitiesDAO) {
+func Init(root string, assetRoot string, selectedCommunitiesDAO dal.SelectedCommunitiesDAO) {
 	gHtmlRoot = root
+	gAssetRoot = assetRoot
 	gSelectedCommunitiesDAO = selectedCommunitiesDAO
 }
 
@@ -47,6 +52,16 @@ func getHtml(bodyFile string) *template.Template {
 	return page
 }
 
+// kindIcon returns the file name of the icon of the kind under static/image,
+// kinds registered without an icon use the default one
+func kindIcon(k atom.PostKind) string {
+	name := k.Name + ".png"
+	if _, err := os.Stat(filepath.Join(gAssetRoot, "image", name)); err != nil {
+		return kDefaultKindIcon
+	}
+	return name
+}
+
 func htmlQRLogin(w http.ResponseWriter, r *http.Request) {
 	t := getHtml("qr_login.tmpl")
 	checkedExecute(t, w, nil)
@@ -100,13 +115,25 @@ func htmlDoLike(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	templateData := lo.FilterMap(r.Form["community"], func(id string, i int) (atom.Community, bool) {
-		d, ok := client.GetCommunityById(id)
-		if !ok {
-			gLog.Warning("invalid community id: ", id)
-		}
-		return d, ok
-	})
+	type kind struct {
+		atom.PostKind
+		Icon string
+	}
+	templateData := struct {
+		Communities []atom.Community
+		Kinds       []kind
+	}{
+		Communities: lo.FilterMap(r.Form["community"], func(id string, i int) (atom.Community, bool) {
+			d, ok := client.GetCommunityById(id)
+			if !ok {
+				gLog.Warning("invalid community id: ", id)
+			}
+			return d, ok
+		}),
+		Kinds: lo.Map(atom.PostKinds(), func(k atom.PostKind, i int) kind {
+			return kind{k, kindIcon(k)}
+		}),
+	}
 	t := getHtml("dolike.tmpl")
 	checkedExecute(t, w, templateData)
 }